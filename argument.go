@@ -4,6 +4,14 @@ package flags
 type Argument struct {
 	Value Value
 	Usage string
+
+	// Completer lists the candidates for the argument in place of the
+	// Value, if set.
+	Completer Completer
+
+	// Hint tells the completion scripts how to complete the argument in
+	// place of the Value, if set.
+	Hint Hint
 }
 
 // Arguments is a map of names and arguments.
@@ -0,0 +1,297 @@
+package flags
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type boundPositional struct {
+	Index int
+	Name  string
+	Arg   Argument
+}
+
+type byIndex []boundPositional
+
+func (list byIndex) Len() int           { return len(list) }
+func (list byIndex) Less(i, j int) bool { return list[i].Index < list[j].Index }
+func (list byIndex) Swap(i, j int)      { list[i], list[j] = list[j], list[i] }
+
+type boundOptional struct {
+	Short    rune
+	Long     string
+	Arg      Argument
+	Env      string
+	Required bool
+}
+
+// binding collects the arguments bound to the fields of a struct, which are
+// only registered once all of them are known not to conflict.
+type binding struct {
+	Positionals []boundPositional
+	Optionals   []boundOptional
+}
+
+// check returns an error if the bound arguments conflict with each other or
+// with the arguments already registered.
+func (b binding) check(pos *Positional, opt *Optional) error {
+	longs, shorts := map[string]bool{}, map[rune]string{}
+	for _, bound := range b.Optionals {
+		if opt.Args.Has(bound.Long) || longs[bound.Long] {
+			return fmt.Errorf("optional argument with long name %q already exists", bound.Long)
+		}
+		name, ok := opt.Alias[bound.Short]
+		if other, dup := shorts[bound.Short]; dup {
+			name, ok = other, true
+		}
+		if bound.Short != 0 && ok {
+			return fmt.Errorf("optional argument with short name `%c` already exists for name %q", bound.Short, name)
+		}
+		longs[bound.Long] = true
+		shorts[bound.Short] = bound.Long
+	}
+
+	if len(b.Positionals) == 0 {
+		return nil
+	}
+	names, extra := map[string]bool{}, pos.HasExtra()
+	for _, bound := range b.Positionals {
+		if pos.Args.Has(bound.Name) || names[bound.Name] {
+			return fmt.Errorf("positional argument with name %q already exists", bound.Name)
+		}
+		if _, ok := bound.Arg.Value.(*StringSliceValue); ok {
+			if extra {
+				return fmt.Errorf("extra arguments already defined for positional argument %q", bound.Name)
+			}
+			extra = true
+		}
+		names[bound.Name] = true
+	}
+	return nil
+}
+
+// Bind registers the fields of the struct pointed to by v as arguments.
+//
+// A field tagged with `flag:"n,count"` is registered as an optional argument
+// with short name `n` and long name "count", and a field tagged with
+// `flag:"count"` as one with only a long name. A field tagged with `pos:"1"`
+// is registered as a positional argument, ordered by the given index and
+// named after the lowercased field name unless a name is given after a
+// comma, as in `pos:"1,file"`. The `usage` tag sets the argument usage, the
+// `default` tag sets the initial value, where slice defaults are separated by
+// commas. The `env` tag sets the environment variable of an optional argument
+// and the `required:"true"` tag requires it to be given.
+//
+// Fields may be of type bool, int, int64, uint64, float64, string, []int,
+// []float64, []string, time.Duration, url.URL, net.IP and net.IPNet, or
+// implement Value through a pointer. Nested structs are bound
+// as a group of optional arguments whose names are prefixed by the `flag`
+// tag of the struct field or its lowercased name, joined by a hyphen, while
+// embedded structs are bound without a prefix.
+func Bind(pos *Positional, opt *Optional, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot bind %T: expected a non-nil pointer to a struct", v)
+	}
+
+	b := &binding{}
+	if err := bindStruct(b, rv.Elem(), ""); err != nil {
+		return err
+	}
+
+	if len(b.Positionals) > 0 && pos == nil {
+		return errors.New("cannot bind positional arguments without a Positional")
+	}
+	if len(b.Optionals) > 0 && opt == nil {
+		return errors.New("cannot bind optional arguments without an Optional")
+	}
+
+	// Nothing is registered unless every argument can be, so that a failed
+	// Bind leaves the argument lists as they were.
+	if err := b.check(pos, opt); err != nil {
+		return err
+	}
+
+	sort.Stable(byIndex(b.Positionals))
+
+	for _, bound := range b.Positionals {
+		pos.register(bound.Name, bound.Arg.Value, bound.Arg.Usage)
+	}
+
+	for _, bound := range b.Optionals {
+		opt.register(bound.Short, bound.Long, bound.Arg.Value, bound.Arg.Usage)
+		if bound.Env != "" {
+			opt.SetEnv(bound.Long, bound.Env)
+		}
+		if bound.Required {
+			opt.Require(bound.Long)
+		}
+	}
+
+	return nil
+}
+
+func bindStruct(b *binding, rv reflect.Value, prefix string) error {
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		field, fv := rt.Field(i), rv.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		flag, hasFlag := field.Tag.Lookup("flag")
+		index, hasPos := field.Tag.Lookup("pos")
+		value, ok := bindValue(fv)
+
+		if !ok && fv.Kind() == reflect.Struct && !hasPos {
+			group := prefix
+			if !field.Anonymous {
+				name := strings.ToLower(field.Name)
+				if hasFlag {
+					name = flag
+				}
+				group = prefixName(prefix, name)
+			}
+			if err := bindStruct(b, fv, group); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !hasFlag && !hasPos {
+			continue
+		}
+
+		if !ok {
+			return fmt.Errorf("cannot bind field %q of type %s", field.Name, field.Type)
+		}
+
+		if init, ok := field.Tag.Lookup("default"); ok {
+			if err := bindDefault(value, init); err != nil {
+				return fmt.Errorf("while setting default for field %q: %v", field.Name, err)
+			}
+		}
+
+		usage := field.Tag.Get("usage")
+
+		if hasPos {
+			name := strings.ToLower(field.Name)
+			if i := strings.IndexByte(index, ','); i >= 0 {
+				index, name = index[:i], index[i+1:]
+			}
+			n, err := strconv.Atoi(index)
+			if err != nil {
+				return fmt.Errorf("invalid positional index %q for field %q", index, field.Name)
+			}
+			switch value.(type) {
+			case *StringSliceValue:
+			case SliceValue:
+				return fmt.Errorf("cannot bind field %q of type %s as a positional argument", field.Name, field.Type)
+			}
+			b.Positionals = append(b.Positionals, boundPositional{n, prefixName(prefix, name), Argument{Value: value, Usage: usage}})
+			continue
+		}
+
+		short, long, err := splitFlag(flag)
+		if err != nil {
+			return fmt.Errorf("invalid flag tag for field %q: %v", field.Name, err)
+		}
+		if long == "" {
+			long = strings.ToLower(field.Name)
+		}
+
+		b.Optionals = append(b.Optionals, boundOptional{
+			Short:    short,
+			Long:     prefixName(prefix, long),
+			Arg:      Argument{Value: value, Usage: usage},
+			Env:      field.Tag.Get("env"),
+			Required: field.Tag.Get("required") == "true",
+		})
+	}
+
+	return nil
+}
+
+func bindValue(fv reflect.Value) (Value, bool) {
+	if !fv.CanAddr() || !fv.Addr().CanInterface() {
+		return nil, false
+	}
+
+	switch p := fv.Addr().Interface().(type) {
+	case Value:
+		return p, true
+	case *bool:
+		return (*BoolValue)(p), true
+	case *int:
+		return (*IntValue)(p), true
+	case *float64:
+		return (*FloatValue)(p), true
+	case *string:
+		return (*StringValue)(p), true
+	case *[]int:
+		return (*IntSliceValue)(p), true
+	case *[]float64:
+		return (*FloatSliceValue)(p), true
+	case *[]string:
+		return (*StringSliceValue)(p), true
+	case *int64:
+		return (*Int64Value)(p), true
+	case *uint64:
+		return (*Uint64Value)(p), true
+	case *time.Duration:
+		return (*DurationValue)(p), true
+	case *url.URL:
+		return (*URLValue)(p), true
+	case *net.IP:
+		return (*IPValue)(p), true
+	case *net.IPNet:
+		return (*IPNetValue)(p), true
+	default:
+		return nil, false
+	}
+}
+
+func bindDefault(value Value, init string) error {
+	if _, ok := value.(SliceValue); !ok {
+		return value.Set(init)
+	}
+	for _, s := range strings.Split(init, ",") {
+		if err := value.Set(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func splitFlag(flag string) (rune, string, error) {
+	i := strings.IndexByte(flag, ',')
+	if i < 0 {
+		return 0, flag, nil
+	}
+	short, long := flag[:i], flag[i+1:]
+	switch utf8.RuneCountInString(short) {
+	case 0:
+		return 0, long, nil
+	case 1:
+		r, _ := utf8.DecodeRuneInString(short)
+		return r, long, nil
+	default:
+		return 0, "", fmt.Errorf("short name %q must be a single character", short)
+	}
+}
+
+func prefixName(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "-" + name
+}
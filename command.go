@@ -1,10 +1,7 @@
 package flags
 
 import (
-	"bufio"
 	"fmt"
-	"io"
-	"os"
 	"sort"
 	"strings"
 
@@ -14,10 +11,18 @@ import (
 // Function defines the type signature of an executable function.
 type Function func(ctx *Context) error
 
-// Command represents a pair of a Function and its Description.
+// Definition registers the positional and optional arguments of a command and
+// returns the Function to run once they are parsed.
+type Definition func(pos *Positional, opt *Optional) Function
+
+// Command represents a pair of a Function and its Description. The Def of a
+// command registered with Define, and the Sub of a command registered with
+// Group, allow the command to be inspected without running its Function.
 type Command struct {
 	Desc string
 	Func Function
+	Def  Definition
+	Sub  CommandSet
 }
 
 // CommandSet is a map of Commands and its names.
@@ -25,7 +30,71 @@ type CommandSet map[string]Command
 
 // Register a Function with the given name and description.
 func (set CommandSet) Register(name, desc string, f Function) {
-	set[name] = Command{desc, f}
+	set[name] = Command{Desc: desc, Func: f}
+}
+
+// Define registers a command with the given name and description whose
+// arguments are registered by def.
+func (set CommandSet) Define(name, desc string, def Definition) {
+	set[name] = Command{Desc: desc, Func: def.Function(), Def: def}
+}
+
+// Group registers the commands of sub as subcommands of the command with the
+// given name and description.
+func (set CommandSet) Group(name, desc string, sub CommandSet) {
+	set[name] = Command{Desc: desc, Func: sub.Compile(), Sub: sub}
+}
+
+// Function returns a Function which parses the arguments registered by def
+// and runs the Function returned by def.
+func (def Definition) Function() Function {
+	return func(ctx *Context) error {
+		pos, opt := Flags()
+		f := def(pos, opt)
+		if err := ctx.Parse(pos, opt); err != nil {
+			return err
+		}
+		return f(ctx)
+	}
+}
+
+// Inspect returns the arguments of a command registered with Define, without
+// running its Function.
+func (cmd Command) Inspect() (*Positional, *Optional, bool) {
+	if cmd.Def == nil {
+		return nil, nil, false
+	}
+	pos, opt := Flags()
+	cmd.Def(pos, opt)
+	return pos, opt, true
+}
+
+// inspect runs the command to generate its completions and documentation, or
+// only parses its arguments if they are registered with Define.
+func (cmd Command) inspect(ctx *Context) error {
+	if pos, opt, ok := cmd.Inspect(); ok {
+		return ctx.Parse(pos, opt)
+	}
+	return cmd.Func(ctx)
+}
+
+// Walk calls fn for every command of the set and of the sets registered with
+// Group, depth first in alphabetical order. The names of the commands are
+// prefixed by the given name.
+func (set CommandSet) Walk(name []string, fn func(name []string, cmd Command) error) error {
+	for _, cmdName := range set.Commands() {
+		cmd := set[cmdName]
+		path := append(append([]string(nil), name...), cmdName)
+		if err := fn(path, cmd); err != nil {
+			return err
+		}
+		if cmd.Sub != nil {
+			if err := cmd.Sub.Walk(path, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 // Commands returns the list of command names in alphabetical order.
@@ -42,17 +111,10 @@ func (set CommandSet) Commands() []string {
 
 // Ronn creates a manpage markdown template for ronn.
 func (set CommandSet) Ronn(ctx *Context) error {
-	usage := fmt.Sprintf("usage: %s [--version] [-h | --help] <command> [<args>]", ctx.JoinedName())
+	usage := set.usage(ctx)
 	name := strings.Join(ctx.Name, "-")
 	filename := fmt.Sprintf("%s.1.ronn", name)
-
-	f, err := os.Create(filename)
-	if err != nil {
-		return ctx.Raise(err)
-	}
-	defer f.Close()
-
-	w := bufio.NewWriter(f)
+	author := ctx.ronnAuthor()
 
 	parts := []string{
 		fmt.Sprintf("# %s -- %s", name, ctx.Desc),
@@ -81,28 +143,22 @@ func (set CommandSet) Ronn(ctx *Context) error {
 		"## BUGS",
 		fmt.Sprintf("**%s** currently has no known bugs.", name),
 		"## AUTHORS",
-		fmt.Sprintf("**%s** is written and maintained by @AUTHOR@.", name),
+		fmt.Sprintf("**%s** is written and maintained by %s.", name, author),
 		"## SEE ALSO",
 		strings.Join(seealso, ", "),
 	}...)
 
 	s := strings.Join(parts, "\n\n")
 	s = wrap.Space(s, 80)
-	if n, err := io.WriteString(w, s); err != nil || n != len(s) {
-		if n != len(s) {
-			return ctx.Raise(fmt.Errorf("wrote %d of %d bytes", n, len(s)))
-		}
-		return ctx.Raise(err)
-	}
 
-	if err := w.Flush(); err != nil {
-		return ctx.Raise(err)
-	}
-
-	return nil
+	return ctx.generate(func(ctx *Context) error {
+		ctx.create(filename)
+		ctx.write(filename, s)
+		return nil
+	})
 }
 
-func (set CommandSet) compBash(ctx *Context) error {
+func (set CommandSet) compBash(ctx *Context) {
 	funcName := strings.Join(ctx.Name, "_")
 
 	cmdNames := set.Commands()
@@ -112,17 +168,17 @@ func (set CommandSet) compBash(ctx *Context) error {
 		cmdFuncs[i] = fmt.Sprintf("%[1]s) &_%[2]s_%[1]s ;;", cmdName, funcName)
 	}
 
-	comps := strings.Join(cmdNames, " ")
+	comps := strings.Join(append(bashHelp(set.optional(ctx)), cmdNames...), " ")
 	funcs := alignLines(strings.Join(cmdFuncs, "\n"), '&')
 	funcs = strings.ReplaceAll(funcs, "\n", "\n        ")
 
-	comp := fmt.Sprintf(compSetBashFormat, funcName, comps, funcs)
+	comp := fmt.Sprintf(compSetBashFormat, funcName, comps, funcs, bashSeek(ctx))
 
 	filename := fmt.Sprintf("%s-completion.bash", ctx.Name[0])
-	return fileAppend(filename, comp)
+	ctx.write(filename, comp)
 }
 
-func (set CommandSet) compZsh(ctx *Context) error {
+func (set CommandSet) compZsh(ctx *Context) {
 	funcName := strings.Join(ctx.Name, "_")
 
 	cmdNames := set.Commands()
@@ -138,119 +194,153 @@ func (set CommandSet) compZsh(ctx *Context) error {
 	funcs := alignLines(strings.Join(cmdFuncs, "\n"), '&')
 	funcs = strings.ReplaceAll(funcs, "\n", "\n        ")
 
-	comp := fmt.Sprintf(compSetZshFormat, funcName, list, funcs)
+	specs := strings.Join(zshHelp(set.optional(ctx)), " \\\n        ")
+
+	comp := fmt.Sprintf(compSetZshFormat, funcName, list, funcs, specs)
 
 	filename := fmt.Sprintf("%s-completion.zsh", ctx.Name[0])
-	return fileAppend(filename, comp)
+	ctx.write(filename, comp)
 }
 
-// Comp creates a completion script.
-func (set CommandSet) Comp(ctx *Context) error {
-	if err := set.compBash(ctx); err != nil {
-		return ctx.Raise(err)
+func (set CommandSet) compFish(ctx *Context) {
+	root, path := ctx.Name[0], ctx.JoinedName()
+	at := fishQuote(fmt.Sprintf("__fish_%s_at_command %s", root, path))
+
+	lines := []string{fmt.Sprintf("complete -c %s -n %s -f", root, at)}
+	if ctx.configState() != nil {
+		lines = append([]string{fishValues(root, []string{fishQuote(path + " --config")})}, lines...)
 	}
+	lines = append(lines, fishHelp(root, at, set.optional(ctx))...)
 
-	if err := set.compZsh(ctx); err != nil {
-		return ctx.Raise(err)
+	for _, cmdName := range set.Commands() {
+		desc := fishQuote(set[cmdName].Desc)
+		lines = append(lines, fmt.Sprintf("complete -c %s -n %s -a %s -d %s", root, at, cmdName, desc))
 	}
 
-	return nil
-}
+	comp := strings.Join(lines, "\n") + "\n\n"
 
-// Help lists the names and descriptions of the commands registered.
-func (set CommandSet) Help(ctx *Context) string {
-	b := strings.Builder{}
-	b.WriteString(fmt.Sprintf("usage: %s [--version] [-h | --help] <command> [<args>]\n\n", ctx.JoinedName()))
+	filename := fmt.Sprintf("%s-completion.fish", root)
+	ctx.write(filename, comp)
+}
 
-	names := make([]string, len(set))
+func (set CommandSet) compPwsh(ctx *Context) {
+	candidates := pwshHelp(set.optional(ctx))
 
-	i := 0
-	for name := range set {
-		names[i] = name
-		i++
+	for _, cmdName := range set.Commands() {
+		candidates = append(candidates, [3]string{cmdName, "ParameterValue", set[cmdName].Desc})
 	}
 
-	sort.Strings(names)
+	comp := pwshCandidates(ctx, candidates)
+
+	filename := fmt.Sprintf("%s-completion.ps1", ctx.Name[0])
+	ctx.write(filename, comp)
+}
+
+// Comp creates the bash, zsh, fish and PowerShell completion scripts.
+func (set CommandSet) Comp(ctx *Context) error {
+	return ctx.generate(func(ctx *Context) error {
+		set.compBash(ctx)
+		set.compZsh(ctx)
+		set.compFish(ctx)
+		set.compPwsh(ctx)
+		return nil
+	})
+}
+
+// optional returns the optional arguments recognized by the CommandSet itself,
+// which are the help flags and the version flags if a Version is declared.
+func (set CommandSet) optional(ctx *Context) *Optional {
+	opt := newOptional()
+	opt.Help = ctx.helpFlags()
+	opt.Version = ctx.versionFlags()
+	return opt
+}
 
+func (set CommandSet) synopsis(ctx *Context) string {
+	return strings.TrimSpace(set.optional(ctx).builtins() + " <command> [<args>]")
+}
+
+func (set CommandSet) usage(ctx *Context) string {
+	return fmt.Sprintf("usage: %s %s", ctx.JoinedName(), set.synopsis(ctx))
+}
+
+func (set CommandSet) list() string {
+	b := strings.Builder{}
 	b.WriteString("available commands:")
-	for _, name := range names {
+	for _, name := range set.Commands() {
 		cmd := set[name]
 		b.WriteString("\n" + formatHelp(name, cmd.Desc))
 	}
 	return b.String()
 }
 
+// Help lists the names and descriptions of the commands registered.
+func (set CommandSet) Help(ctx *Context) string {
+	return set.usage(ctx) + "\n\n" + set.list()
+}
+
 // Compile the CommandSet into a single Function.
 func (set CommandSet) Compile() Function {
 	return func(ctx *Context) error {
-		if len(ctx.Args) == 0 {
-			return fmt.Errorf("%s expected a command.\n\n%s", ctx.JoinedName(), set.Help(ctx))
-		}
-
-		head, tail := shift(ctx.Args)
-		if (strings.HasPrefix(head, "-") && strings.Contains(head, "h")) || head == "--help" {
-			return fmt.Errorf("%s: %s\n\n%s", ctx.JoinedName(), ctx.Desc, set.Help(ctx))
+		if state := ctx.configState(); state != nil && len(ctx.Name) == 1 {
+			state.Set = set
 		}
 
-		switch head {
-		case "generate-ronn-templates":
-			if err := set.Ronn(ctx); err != nil {
-				return fmt.Errorf("while generating ronn file for %s: %v", ctx.JoinedName(), err)
-			}
-			for name, cmd := range set {
-				child := &Context{append(ctx.Name, name), cmd.Desc, ctx.Args, ctx.Ctx}
-				if err := cmd.Func(child); err != errRonn {
-					return fmt.Errorf("while generating ronn file for %s: %v", name, err)
-				}
+		if child, ok := completeRoot(ctx); ok {
+			if err := set.Compile()(child); err != errComplete {
+				return err
 			}
 			return nil
+		}
 
-		case "generate-completions":
-			if len(ctx.Name) == 1 {
-				bash := fmt.Sprintf("%s-completion.bash", ctx.Name[0])
-				if err := touch(bash); err != nil {
-					return fmt.Errorf("while generating completion for %s: %v", ctx.Name[0], err)
-				}
-
-				zsh := fmt.Sprintf("%s-completion.zsh", ctx.Name[0])
-				if err := touch(zsh); err != nil {
-					return fmt.Errorf("while generating completion for %s: %v", ctx.Name[0], err)
-				}
+		if state := ctx.completing(); state != nil {
+			return set.complete(ctx, state)
+		}
 
-				zcomp := fmt.Sprintf("#compdef %s\n\n", ctx.Name[0])
-				if err := fileAppend(zsh, zcomp); err != nil {
-					return fmt.Errorf("while generating completion for %s: %v", ctx.JoinedName(), err)
-				}
+		// Commands take precedence over the reserved command names.
+		if child, ok := toolRoot(ctx); ok {
+			if _, registered := set[ctx.Args[0]]; !registered {
+				ctx = child
 			}
+		}
+		if tool, ok := ctx.tool(); ok {
+			return tool.runSet(ctx, set)
+		}
 
-			names := set.Commands()
-			for _, name := range names {
-				cmd := set[name]
-				child := &Context{append(ctx.Name, name), cmd.Desc, ctx.Args, ctx.Ctx}
-				if err := cmd.Func(child); err != errComp {
-					return fmt.Errorf("while generating completion for %s: %v", name, err)
-				}
-			}
+		if len(ctx.Args) == 0 {
+			return fmt.Errorf("%s expected a command.\n\n%s", ctx.JoinedName(), set.Help(ctx))
+		}
 
-			if err := set.Comp(ctx); err != nil {
-				return fmt.Errorf("while generating completion for %s: %v", ctx.JoinedName(), err)
-			}
+		head, tail := shift(ctx.Args)
 
-			if len(ctx.Name) == 1 {
-				bash := fmt.Sprintf("%s-completion.bash", ctx.Name[0])
-				bcomp := fmt.Sprintf("complete -F _%[1]s %[1]s", ctx.Name[0])
-				if err := fileAppend(bash, bcomp); err != nil {
-					return fmt.Errorf("while generating completion for %s: %v", ctx.JoinedName(), err)
+		if state := ctx.configState(); state != nil && (head == "--config" || strings.HasPrefix(head, "--config=")) {
+			filename := strings.TrimPrefix(head, "--config=")
+			if head == "--config" {
+				if len(tail) == 0 {
+					return &MissingValueError{"config"}
 				}
+				filename, tail = shift(tail)
 			}
+			if err := state.load(filename, true); err != nil {
+				return ctx.Raise(err)
+			}
+			return set.Compile()(&Context{ctx.Name, ctx.Desc, tail, ctx.Ctx})
+		}
 
-			return nil
+		if err := ctx.requestVersion(set.optional(ctx), ctx.Args); err != nil {
+			return err
+		}
+
+		if ctx.helpFlags().matches(head) {
+			return &UsageError{ErrHelp, ctx.JoinedName(), ctx.Desc, set.usage(ctx), "\n" + set.list()}
 		}
 
-		cmd, ok := set[head]
-		if !ok {
-			return fmt.Errorf("unknown command name `%s`", head)
+		name, suggestions := match(head, set.Commands(), ctx.prefixMatching())
+		if name == "" {
+			return &UnknownCommandError{head, suggestions}
 		}
+		head = name
+		cmd := set[head]
 
 		return cmd.Func(&Context{append(ctx.Name, head), cmd.Desc, tail, ctx.Ctx})
 	}
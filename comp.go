@@ -2,28 +2,17 @@ package flags
 
 import (
 	"fmt"
-	"sort"
+	"regexp"
 	"strings"
+	"unicode"
 )
 
 var compSetBashFormat = strings.Join([]string{
-	"_%s()",
+	"_%[1]s()",
 	"{",
-	"    cmds=\"-h --help --version %s\"",
-	"    local i=0 cmd",
-	"",
-	"    while [[ \"$i\" -lt \"$COMP_CWORD\" ]]",
-	"    do",
-	"        local s=\"${COMP_WORDS[$i]}\"",
-	"        case \"$s\" in",
-	"            gts)",
-	"                (( i++ ))",
-	"                break",
-	"                ;;",
-	"        esac",
-	"        (( i++ ))",
-	"    done",
-	"",
+	"    cmds=\"%[2]s\"",
+	"    local i=1 cmd",
+	"%[4]s",
 	"    while [[ \"$i\" -lt \"$COMP_CWORD\" ]]",
 	"    do",
 	"        local s=\"${COMP_WORDS[$i]}\"",
@@ -49,7 +38,7 @@ var compSetBashFormat = strings.Join([]string{
 	"    fi",
 	"",
 	"    case \"$cmd\" in",
-	"        %s",
+	"        %[3]s",
 	"        *) ;;",
 	"    esac",
 	"}",
@@ -58,24 +47,32 @@ var compSetBashFormat = strings.Join([]string{
 }, "\n")
 
 var compFuncBashFormat = strings.Join([]string{
-	"_%s()",
+	"_%[1]s()",
 	"{",
-	"    opts=\"-h --help --version %s\"",
-	"    local cur=\"${COMP_WORDS[$COMP_CWORD]}\"",
-	"    case \"$cur\" in",
+	"    opts=\"%[2]s\"",
+	"    local prev=\"${COMP_WORDS[$COMP_CWORD-1]}\"",
+	"    local i=1 n=0",
+	"%[4]s",
+	"    while [[ \"$i\" -lt \"$COMP_CWORD\" ]]",
+	"    do",
+	"        case \"${COMP_WORDS[$i]}\" in",
+	"            %[5]s",
+	"        esac",
+	"        (( i++ ))",
+	"    done",
+	"",
+	"    case \"$prev\" in",
+	"        %[6]s",
+	"    esac",
+	"",
+	"    case \"${COMP_WORDS[$COMP_CWORD]}\" in",
 	"        -*)",
-	"            COMPREPLY=()",
-	"            while IFS='' read -r line",
-	"            do",
-	"                COMPREPLY+=(\"$line\")",
-	"            done < <(compgen -W \"$opts\" -- \"$cur\")",
+	"            __%[3]s_compgen -W \"$opts\"",
 	"            ;;",
 	"        *)",
-	"            COMPREPLY=()",
-	"            while IFS='' read -r line",
-	"            do ",
-	"                COMPREPLY+=(\"$line\")",
-	"            done < <(compgen -f -- \"$cur\")",
+	"            case \"$n\" in",
+	"                %[7]s",
+	"            esac",
 	"            ;;",
 	"    esac",
 	"}",
@@ -83,27 +80,63 @@ var compFuncBashFormat = strings.Join([]string{
 	"",
 }, "\n")
 
+// compDynBashFormat calls back into the program to list the candidates for
+// the current word, completing file or directory names if requested, and
+// lists the candidates given by compgen for the static completion hints.
+var compDynBashFormat = strings.Join([]string{
+	"__%[1]s_complete()",
+	"{",
+	"    local cur=\"${COMP_WORDS[$COMP_CWORD]}\" line files=\"\"",
+	"    COMPREPLY=()",
+	"    while IFS='' read -r line",
+	"    do",
+	"        case \"$line\" in",
+	"            %[2]s) files=-f ;;",
+	"            %[4]s) files=-d ;;",
+	"            *) COMPREPLY+=(\"${line%%%%$'\\t'*}\") ;;",
+	"        esac",
+	"    done < <(%[3]s=1 \"${COMP_WORDS[0]}\" \"${COMP_WORDS[@]:1:$COMP_CWORD}\" 2>/dev/null)",
+	"    if [[ -n \"$files\" ]]",
+	"    then",
+	"        while IFS='' read -r line",
+	"        do",
+	"            COMPREPLY+=(\"$line\")",
+	"        done < <(compgen \"$files\" -- \"$cur\")",
+	"    fi",
+	"}",
+	"",
+	"__%[1]s_compgen()",
+	"{",
+	"    local cur=\"${COMP_WORDS[$COMP_CWORD]}\" line",
+	"    COMPREPLY=()",
+	"    while IFS='' read -r line",
+	"    do",
+	"        COMPREPLY+=(\"$line\")",
+	"    done < <(compgen \"$@\" -- \"$cur\")",
+	"}",
+	"",
+	"",
+}, "\n")
+
 var compSetZshFormat = strings.Join([]string{
-	"function _%s {",
+	"function _%[1]s {",
 	"    local line",
 	"",
 	"    function _commands {",
 	"        local -a commands",
 	"        commands=(",
-	"            %s",
+	"            %[2]s",
 	"        )",
 	"        _describe 'command' commands",
 	"    }",
 	"",
 	"    _arguments -C \\",
-	"        \"-h[show help]\" \\",
-	"        \"--help[show help]\" \\",
-	"        \"--version[print the version number]\" \\",
+	"        %[4]s \\",
 	"        \"1: :_commands\" \\",
 	"        \"*::arg:->args\"",
 	"",
 	"    case $line[1] in",
-	"        %s",
+	"        %[3]s",
 	"        *) ;;",
 	"    esac",
 	"}",
@@ -112,94 +145,603 @@ var compSetZshFormat = strings.Join([]string{
 }, "\n")
 
 var compFuncZshFormat = strings.Join([]string{
-	"function _%s {",
-	"    _arguments \\",
-	"        \"-h[show help]\" \\",
-	"        \"--help[show help]\" \\",
-	"        \"--version[print the version number]\" \\",
-	"        %s \\",
-	"        \"*::files:_files\"",
+	"function _%[1]s {",
+	"    _arguments -s \\",
+	"        %[2]s",
 	"}",
 	"",
 	"",
 }, "\n")
 
-func compBash(ctx *Context, pos *Positional, opt *Optional) error {
-	funcName := strings.Join(ctx.Name, "_")
+// compDynZshFormat calls back into the program to list the candidates for
+// the current word, completing file or directory names if requested.
+var compDynZshFormat = strings.Join([]string{
+	"function __%[1]s_complete {",
+	"    local -a args candidates files",
+	"    local line",
+	"    args=(${(z)LBUFFER})",
+	"    [[ \"$LBUFFER\" == *' ' ]] && args+=('')",
+	"    for line in \"${(@f)$(%[3]s=1 ${args[1]} \"${(@)args[2,-1]}\" 2>/dev/null)}\"",
+	"    do",
+	"        case \"$line\" in",
+	"            %[2]s) files=(_files) ;;",
+	"            %[4]s) files=(_files -/) ;;",
+	"            ?*) candidates+=(\"${line%%%%$'\\t'*}\") ;;",
+	"        esac",
+	"    done",
+	"    compadd -a candidates",
+	"    if (( $#files ))",
+	"    then",
+	"        \"${files[@]}\"",
+	"    fi",
+	"}",
+	"",
+	"",
+}, "\n")
 
-	optNames := []optionalName{}
-	for long := range opt.Args {
-		name := optionalName{0, long}
-		for short := range opt.Alias {
-			if opt.Alias[short] == long {
-				name.Short = short
-			}
+// compFishFormat lists the words naming the commands and the positional
+// arguments, skipping the optional arguments along with the values given to
+// those listed in __fish_<root>_values after the command they belong to.
+var compFishFormat = strings.Join([]string{
+	"set -g __fish_%[1]s_values",
+	"",
+	"function __fish_%[1]s_words",
+	"    set -l words",
+	"    set -l skip 0",
+	"    for word in (commandline -opc)",
+	"        if test $skip -eq 1",
+	"            set skip 0",
+	"        else if string match -q -- '-*' $word",
+	"            for n in (seq (count $words) -1 1)",
+	"                if contains -- \"$words[1..$n] $word\" $__fish_%[1]s_values",
+	"                    set skip 1",
+	"                    break",
+	"                end",
+	"            end",
+	"        else",
+	"            set -a words $word",
+	"        end",
+	"    end",
+	"    printf '%%s\\n' $words",
+	"end",
+	"",
+	"function __fish_%[1]s_at_command",
+	"    set -l words (__fish_%[1]s_words)",
+	"    test \"$words\" = \"$argv\"",
+	"end",
+	"",
+	"function __fish_%[1]s_in_command",
+	"    set -l words (__fish_%[1]s_words)",
+	"    test (count $words) -ge (count $argv)",
+	"    and test \"$words[1..(count $argv)]\" = \"$argv\"",
+	"end",
+	"",
+	"function __fish_%[1]s_positional",
+	"    set -l n $argv[1]",
+	"    set -l cmd $argv[2..-1]",
+	"    set -l words (__fish_%[1]s_words)",
+	"    test (count $words) -eq (math (count $cmd) + $n - 1)",
+	"    and test \"$words[1..(count $cmd)]\" = \"$cmd\"",
+	"end",
+	"",
+	"",
+}, "\n")
+
+var compPwshFormat = strings.Join([]string{
+	"$__%[2]s_completer = {",
+	"    param($wordToComplete, $commandAst, $cursorPosition)",
+	"",
+	"    $words = @()",
+	"    foreach ($element in $commandAst.CommandElements) {",
+	"        if ($element.Extent.EndOffset -ge $cursorPosition) {",
+	"            break",
+	"        }",
+	"        $text = $element.ToString()",
+	"        if (-not $text.StartsWith('-')) {",
+	"            $words += $text",
+	"        }",
+	"    }",
+	"",
+	"    $path = $words -join ' '",
+	"    while ($path -and -not $__%[2]s_completions.ContainsKey($path)) {",
+	"        $path = $path.Substring(0, [Math]::Max(0, $path.LastIndexOf(' ')))",
+	"    }",
+	"",
+	"    foreach ($candidate in $__%[2]s_completions[$path]) {",
+	"        if ($candidate[1] -eq 'ParameterValue' -and $path -ne ($words -join ' ')) {",
+	"            continue",
+	"        }",
+	"        if ($candidate[0] -like \"$wordToComplete*\") {",
+	"            $tooltip = if ($candidate[2]) { $candidate[2] } else { $candidate[0] }",
+	"            [System.Management.Automation.CompletionResult]::new(",
+	"                $candidate[0], $candidate[0], $candidate[1], $tooltip)",
+	"        }",
+	"    }",
+	"}.GetNewClosure()",
+	"",
+	"Register-ArgumentCompleter -Native -CommandName '%[1]s' -ScriptBlock $__%[2]s_completer",
+	"",
+}, "\n")
+
+// negatedUsage returns the usage of the negation of the switch with the given
+// long name.
+func negatedUsage(long string) string {
+	return fmt.Sprintf("negate --%s", long)
+}
+
+func pwshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func pwshName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x80 && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
 		}
-		optNames = append(optNames, name)
+		return '_'
+	}, s)
+}
+
+// pwshCandidates formats the completion candidates for the command with the
+// given name as an entry in the completion table of the PowerShell script.
+func pwshCandidates(ctx *Context, candidates [][3]string) string {
+	lines := make([]string, len(candidates))
+	for i, c := range candidates {
+		lines[i] = fmt.Sprintf("    ,@(%s, '%s', %s)", pwshQuote(c[0]), c[1], pwshQuote(c[2]))
 	}
+	table := fmt.Sprintf("$__%s_completions", pwshName(ctx.Name[0]))
+	return fmt.Sprintf("%s[%s] = @(\n%s\n)\n\n", table, pwshQuote(ctx.JoinedName()), strings.Join(lines, "\n"))
+}
 
-	sort.Sort(byShort(optNames))
+// pwshHelp returns the completion candidates for the builtin flags.
+func pwshHelp(opt *Optional) [][3]string {
+	candidates := [][3]string{}
+	for _, flag := range opt.builtinFlags() {
+		for _, name := range flag.Names {
+			candidates = append(candidates, [3]string{name, "ParameterName", flag.Usage})
+		}
+	}
+	return candidates
+}
 
-	optFlags := []string{}
-	for _, optName := range optNames {
+func compPwsh(ctx *Context, pos *Positional, opt *Optional) {
+	candidates := pwshHelp(opt)
+
+	for _, optName := range opt.sortedNames() {
 		short, long := optName.Short, optName.Long
+		usage := opt.Args[long].Usage
 		if short != 0 {
-			optFlags = append(optFlags, fmt.Sprintf("-%c", short))
+			candidates = append(candidates, [3]string{fmt.Sprintf("-%c", short), "ParameterName", usage})
+		}
+		candidates = append(candidates, [3]string{"--" + long, "ParameterName", usage})
+		if opt.negatable(long) {
+			candidates = append(candidates, [3]string{"--no-" + long, "ParameterName", negatedUsage(long)})
 		}
-		optFlags = append(optFlags, fmt.Sprintf("--%s", long))
 	}
 
-	opts := strings.Join(optFlags, " ")
+	comp := pwshCandidates(ctx, candidates)
+
+	filename := fmt.Sprintf("%s-completion.ps1", ctx.Name[0])
+	ctx.write(filename, comp)
+}
+
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, "'", "\\'")
+	return "'" + s + "'"
+}
 
-	comp := fmt.Sprintf(compFuncBashFormat, funcName, opts)
+// fishFlags returns the fish options completing the given flag names.
+func fishFlags(names []string) string {
+	flags := ""
+	for _, name := range names {
+		if strings.HasPrefix(name, "--") {
+			flags += " -l " + name[2:]
+		} else {
+			flags += " -s " + name[1:]
+		}
+	}
+	return flags
+}
 
-	filename := fmt.Sprintf("%s-completion.bash", ctx.Name[0])
-	return fileAppend(filename, comp)
+// fishValues returns the command adding the given flags, preceded by the
+// command they belong to, to those whose value is skipped by
+// __fish_<root>_words.
+func fishValues(root string, values []string) string {
+	return fmt.Sprintf("set -ga __fish_%s_values %s", root, strings.Join(values, " "))
 }
 
-func compZsh(ctx *Context, pos *Positional, opt *Optional) error {
-	funcName := strings.Join(ctx.Name, "_")
+// fishHelp returns the completions of the builtin flags under the given
+// condition.
+func fishHelp(root, cond string, opt *Optional) []string {
+	lines := []string{}
+	for _, flag := range opt.builtinFlags() {
+		lines = append(lines, fmt.Sprintf("complete -c %s -n %s%s -d %s", root, cond, fishFlags(flag.Names), fishQuote(flag.Usage)))
+	}
+	return lines
+}
+
+func compFish(ctx *Context, pos *Positional, opt *Optional) {
+	root, path := ctx.Name[0], ctx.JoinedName()
+	in := fishQuote(fmt.Sprintf("__fish_%s_in_command %s", root, path))
+
+	lines := fishHelp(root, in, opt)
 
-	optNames := []optionalName{}
-	for long := range opt.Args {
-		name := optionalName{0, long}
-		for short := range opt.Alias {
-			if opt.Alias[short] == long {
-				name.Short = short
+	values := []string{}
+	for _, optName := range opt.sortedNames() {
+		short, long := optName.Short, optName.Long
+		arg := opt.Args[long]
+		line := fmt.Sprintf("complete -c %s -n %s", root, in)
+		if short != 0 {
+			line += fmt.Sprintf(" -s %c", short)
+		}
+		line += fmt.Sprintf(" -l %s", long)
+		if takesValue(arg) {
+			line += " -r " + fishHint(hintOf(arg))
+			if short != 0 {
+				values = append(values, fishQuote(fmt.Sprintf("%s -%c", path, short)))
 			}
+			values = append(values, fishQuote(fmt.Sprintf("%s --%s", path, long)))
+		}
+		lines = append(lines, line+" -d "+fishQuote(arg.Usage))
+		if opt.negatable(long) {
+			lines = append(lines, fmt.Sprintf("complete -c %s -n %s -l no-%s -d %s", root, in, long, fishQuote(negatedUsage(long))))
+		}
+	}
+
+	for i, name := range pos.Order {
+		arg := pos.Args[name]
+		cond := fmt.Sprintf("__fish_%s_positional %d %s", root, i+1, path)
+		if _, ok := arg.Value.(*StringSliceValue); ok {
+			cond = fmt.Sprintf("__fish_%s_in_command %s", root, path)
+		}
+		lines = append(lines, fmt.Sprintf("complete -c %s -n %s %s -d %s", root, fishQuote(cond), fishHint(hintOf(arg)), fishQuote(arg.Usage)))
+	}
+
+	if len(values) > 0 {
+		lines = append([]string{fishValues(root, values)}, lines...)
+	}
+
+	comp := strings.Join(lines, "\n") + "\n\n"
+
+	filename := fmt.Sprintf("%s-completion.fish", root)
+	ctx.write(filename, comp)
+}
+
+func bashQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "'\\''") + "'"
+}
+
+// bashSeek skips the words up to the name of a subcommand in the bash
+// completion script. The root command name is always the first word.
+func bashSeek(ctx *Context) string {
+	if len(ctx.Name) == 1 {
+		return ""
+	}
+	return strings.Join([]string{
+		"",
+		fmt.Sprintf("    while [[ \"$i\" -lt \"$COMP_CWORD\" && \"${COMP_WORDS[$i]}\" != %s ]]", bashQuote(ctx.Name[len(ctx.Name)-1])),
+		"    do",
+		"        (( i++ ))",
+		"    done",
+		"    (( i++ ))",
+		"",
+	}, "\n")
+}
+
+// plainWord tests if the word can be given as is in the word lists of the
+// bash and fish completion scripts, which are split on whitespace and expanded.
+func plainWord(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_.,:/=+@", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// quoteWords joins the words into a word list, quoting the words which are
+// not plain with the given function.
+func quoteWords(words []string, quote func(string) string) string {
+	list := make([]string, len(words))
+	for i, word := range words {
+		list[i] = word
+		if !plainWord(word) {
+			list[i] = quote(word)
+		}
+	}
+	return strings.Join(list, " ")
+}
+
+// bashHint returns the command completing the current word for the given
+// hint in the bash completion script. The words are quoted within the word
+// list given to compgen, which honors the quotes when splitting it, although
+// bash inserts the chosen word unquoted.
+func bashHint(root string, hint Hint) string {
+	switch hint.Type {
+	case HintFiles:
+		if hint.Pattern != "" {
+			return fmt.Sprintf("__%s_compgen -o plusdirs -f -X %s", root, bashQuote("!"+hint.Pattern))
+		}
+		return fmt.Sprintf("__%s_compgen -f", root)
+	case HintDirs:
+		return fmt.Sprintf("__%s_compgen -d", root)
+	case HintWords:
+		return fmt.Sprintf("__%s_compgen -W %s", root, bashQuote(quoteWords(hint.Words, bashQuote)))
+	case HintNone:
+		return "COMPREPLY=()"
+	default:
+		return fmt.Sprintf("__%s_complete", root)
+	}
+}
+
+// zshWord escapes the word for a list of words in an action of the zsh
+// completion script. The backslashes escaping the characters special within
+// the double quotes of the spec are escaped once more.
+func zshWord(s string) string {
+	b := strings.Builder{}
+	for _, r := range s {
+		switch r {
+		case ' ', '(', ')', ':':
+			b.WriteRune('\\')
+		case '\\', '"', '$', '`':
+			b.WriteString(`\\\`)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// zshHint returns the action completing the current word for the given hint
+// in the zsh completion script.
+func zshHint(root string, hint Hint) string {
+	switch hint.Type {
+	case HintFiles:
+		if hint.Pattern != "" {
+			return fmt.Sprintf("_files -g '%s'", hint.Pattern)
+		}
+		return "_files"
+	case HintDirs:
+		return "_files -/"
+	case HintWords:
+		words := make([]string, len(hint.Words))
+		for i, word := range hint.Words {
+			words[i] = zshWord(word)
+		}
+		return fmt.Sprintf("(%s)", strings.Join(words, " "))
+	case HintNone:
+		return " "
+	default:
+		return fmt.Sprintf("__%s_complete", root)
+	}
+}
+
+// fishPattern converts a file name pattern to a regular expression matching
+// the paths completed by fish which match it, along with the directories.
+func fishPattern(pattern string) string {
+	b := strings.Builder{}
+	class := false
+	for i, r := range pattern {
+		switch {
+		case class && r == ']':
+			class = false
+			b.WriteRune(r)
+		case class && r == '!' && pattern[i-1] == '[':
+			b.WriteRune('^')
+		case class:
+			if r == '\\' {
+				b.WriteRune(r)
+			}
+			b.WriteRune(r)
+		case r == '[' && strings.ContainsRune(pattern[i+1:], ']'):
+			class = true
+			b.WriteRune(r)
+		case r == '*':
+			b.WriteString("[^/]*")
+		case r == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return "/$|(^|/)" + b.String() + "$"
+}
+
+// fishHint returns the options completing the current word for the given
+// hint in the fish completion script.
+func fishHint(hint Hint) string {
+	switch hint.Type {
+	case HintFiles:
+		if hint.Pattern != "" {
+			list := "__fish_complete_path (commandline -ct) | string match -r -- " + fishQuote(fishPattern(hint.Pattern))
+			return "-f -a " + fishQuote("("+list+")")
 		}
-		optNames = append(optNames, name)
+		return "-F"
+	case HintDirs:
+		return "-f -a '(__fish_complete_directories)'"
+	case HintWords:
+		return "-f -a " + fishQuote(quoteWords(hint.Words, fishQuote))
+	case HintNone:
+		return "-f"
+	default:
+		return "-F"
 	}
+}
+
+// bashHelp returns the names of the builtin flags.
+func bashHelp(opt *Optional) []string {
+	names := []string{}
+	for _, flag := range opt.builtinFlags() {
+		names = append(names, flag.Names...)
+	}
+	return names
+}
 
-	sort.Sort(byShort(optNames))
+func compBash(ctx *Context, pos *Positional, opt *Optional) {
+	root, funcName := ctx.Name[0], strings.Join(ctx.Name, "_")
 
-	optFlags := []string{}
-	for _, optName := range optNames {
+	// The words following the optional arguments taking a value are skipped
+	// when counting the positional arguments given before the current word.
+	optFlags, valueFlags, prevCases := []string{}, []string{}, []string{}
+	for _, optName := range opt.sortedNames() {
 		short, long := optName.Short, optName.Long
-		usage := opt.Args[long].Usage
+		arg := opt.Args[long]
+
+		flags := []string{}
 		if short != 0 {
-			optFlags = append(optFlags, fmt.Sprintf("\"-%c[%s]\"", short, usage))
+			flags = append(flags, fmt.Sprintf("-%c", short))
+		}
+		flags = append(flags, fmt.Sprintf("--%s", long))
+		optFlags = append(optFlags, flags...)
+		if opt.negatable(long) {
+			optFlags = append(optFlags, "--no-"+long)
+		}
+
+		if takesValue(arg) {
+			pattern := strings.Join(flags, "|")
+			valueFlags = append(valueFlags, pattern)
+			prevCases = append(prevCases, fmt.Sprintf("%s) %s; return ;;", pattern, bashHint(root, hintOf(arg))))
 		}
-		optFlags = append(optFlags, fmt.Sprintf("\"--%s[%s]\"", long, usage))
 	}
 
-	opts := strings.Join(optFlags, " \\\n        ")
+	wordCases := []string{}
+	if len(valueFlags) > 0 {
+		wordCases = append(wordCases, fmt.Sprintf("%s) (( i++ )) ;;", strings.Join(valueFlags, "|")))
+	}
+	wordCases = append(wordCases, "-*) ;;", "*) (( n++ )) ;;")
+	prevCases = append(prevCases, "*) ;;")
+
+	posCases, rest := []string{}, bashHint(root, Hint{})
+	for i, name := range pos.Order {
+		arg := pos.Args[name]
+		if _, ok := arg.Value.(*StringSliceValue); ok {
+			rest = bashHint(root, hintOf(arg))
+			continue
+		}
+		posCases = append(posCases, fmt.Sprintf("%d) %s ;;", i, bashHint(root, hintOf(arg))))
+	}
+	posCases = append(posCases, fmt.Sprintf("*) %s ;;", rest))
+
+	opts := strings.Join(append(bashHelp(opt), optFlags...), " ")
+	words := strings.Join(wordCases, "\n            ")
+	prevs := strings.Join(prevCases, "\n        ")
+	poss := strings.Join(posCases, "\n                ")
+
+	comp := fmt.Sprintf(compFuncBashFormat, funcName, opts, root, bashSeek(ctx), words, prevs, poss)
+
+	filename := fmt.Sprintf("%s-completion.bash", root)
+	ctx.write(filename, comp)
+}
+
+// zshHelp returns the specs of the builtin flags.
+func zshHelp(opt *Optional) []string {
+	specs := []string{}
+	for _, flag := range opt.builtinFlags() {
+		for _, name := range flag.Names {
+			specs = append(specs, fmt.Sprintf("\"%s[%s]\"", name, flag.Usage))
+		}
+	}
+	return specs
+}
+
+func compZsh(ctx *Context, pos *Positional, opt *Optional) {
+	root, funcName := ctx.Name[0], strings.Join(ctx.Name, "_")
+
+	specs := zshHelp(opt)
+	for _, optName := range opt.sortedNames() {
+		short, long := optName.Short, optName.Long
+		arg := opt.Args[long]
+
+		// Values may be attached to short options or given after `=` to
+		// long options, as well as given as the next word.
+		var repeat, shortSep, longSep, action string
+		switch arg.Value.(type) {
+		case *CountValue:
+			repeat = "*"
+		case SwitchValue:
+		case SliceValue:
+			repeat, shortSep, longSep = "*", "+", "="
+			action = fmt.Sprintf(":%s:%s", long, zshHint(root, hintOf(arg)))
+		default:
+			shortSep, longSep = "+", "="
+			action = fmt.Sprintf(":%s:%s", long, zshHint(root, hintOf(arg)))
+		}
+
+		if short != 0 {
+			specs = append(specs, fmt.Sprintf("\"%s-%c%s[%s]%s\"", repeat, short, shortSep, arg.Usage, action))
+		}
+		specs = append(specs, fmt.Sprintf("\"%s--%s%s[%s]%s\"", repeat, long, longSep, arg.Usage, action))
+		if opt.negatable(long) {
+			specs = append(specs, fmt.Sprintf("\"--no-%s[%s]\"", long, negatedUsage(long)))
+		}
+	}
+
+	rest := fmt.Sprintf("\"*::arg:%s\"", zshHint(root, Hint{}))
+	for i, name := range pos.Order {
+		arg := pos.Args[name]
+		if _, ok := arg.Value.(*StringSliceValue); ok {
+			rest = fmt.Sprintf("\"*::%s:%s\"", name, zshHint(root, hintOf(arg)))
+			continue
+		}
+		specs = append(specs, fmt.Sprintf("\"%d:%s:%s\"", i+1, name, zshHint(root, hintOf(arg))))
+	}
+	specs = append(specs, rest)
+
+	opts := strings.Join(specs, " \\\n        ")
 
 	comp := fmt.Sprintf(compFuncZshFormat, funcName, opts)
 
-	filename := fmt.Sprintf("%s-completion.zsh", ctx.Name[0])
-	return fileAppend(filename, comp)
+	filename := fmt.Sprintf("%s-completion.zsh", root)
+	ctx.write(filename, comp)
 }
 
-// Comp creates a bash completion script.
+// Comp creates the bash, zsh, fish and PowerShell completion scripts.
 func Comp(ctx *Context, pos *Positional, opt *Optional) error {
-	if err := compBash(ctx, pos, opt); err != nil {
-		return ctx.Raise(err)
+	return ctx.generate(func(ctx *Context) error {
+		compBash(ctx, pos, opt)
+		compZsh(ctx, pos, opt)
+		compFish(ctx, pos, opt)
+		compPwsh(ctx, pos, opt)
+		return nil
+	})
+}
+
+// compBegin starts the completion script files over for the root command.
+func compBegin(ctx *Context) {
+	if len(ctx.Name) != 1 {
+		return
 	}
 
-	if err := compZsh(ctx, pos, opt); err != nil {
-		return ctx.Raise(err)
+	for _, shell := range []string{"bash", "zsh", "fish", "ps1"} {
+		ctx.create(fmt.Sprintf("%s-completion.%s", ctx.Name[0], shell))
 	}
 
-	return nil
+	bash := fmt.Sprintf("%s-completion.bash", ctx.Name[0])
+	ctx.write(bash, fmt.Sprintf(compDynBashFormat, ctx.Name[0], completeFiles, CompleteEnv, completeDirs))
+
+	zsh := fmt.Sprintf("%s-completion.zsh", ctx.Name[0])
+	ctx.write(zsh, fmt.Sprintf("#compdef %s\n\n", ctx.Name[0]))
+	ctx.write(zsh, fmt.Sprintf(compDynZshFormat, ctx.Name[0], completeFiles, CompleteEnv, completeDirs))
+
+	fish := fmt.Sprintf("%s-completion.fish", ctx.Name[0])
+	ctx.write(fish, fmt.Sprintf(compFishFormat, ctx.Name[0]))
+
+	pwsh := fmt.Sprintf("%s-completion.ps1", ctx.Name[0])
+	ctx.write(pwsh, fmt.Sprintf("$__%s_completions = @{}\n\n", pwshName(ctx.Name[0])))
+}
+
+// compEnd completes the completion script files for the root command.
+func compEnd(ctx *Context) {
+	if len(ctx.Name) != 1 {
+		return
+	}
+
+	bash := fmt.Sprintf("%s-completion.bash", ctx.Name[0])
+	ctx.write(bash, fmt.Sprintf("complete -F _%[1]s %[1]s\n", ctx.Name[0]))
+
+	pwsh := fmt.Sprintf("%s-completion.ps1", ctx.Name[0])
+	ctx.write(pwsh, fmt.Sprintf(compPwshFormat, ctx.Name[0], pwshName(ctx.Name[0])))
 }
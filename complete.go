@@ -0,0 +1,277 @@
+package flags
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// CompleteEnv is the environment variable set by the completion scripts when
+// calling back into the program with the words of the command line up to the
+// cursor, so that no argument is ever taken as a request to complete.
+const CompleteEnv = "FLAGS_COMPLETE"
+
+// completeCommand is the name of the file the candidates are written to in
+// the Output given by WithOutput.
+const completeCommand = "__complete"
+
+// completeFiles is written in place of a candidate to request the shell to
+// complete file names.
+const completeFiles = ":files"
+
+// completeDirs is written in place of a candidate to request the shell to
+// complete directory names.
+const completeDirs = ":dirs"
+
+var errComplete = errors.New("complete")
+
+type completeKey struct{}
+
+type completeState struct {
+	Out Output
+}
+
+func (ctx Context) completing() *completeState {
+	state, _ := ctx.lookup(completeKey{}).(*completeState)
+	return state
+}
+
+// write writes the candidates starting with the given prefix to the output,
+// one per line, with the descriptions following a tab if any, followed by the
+// directive for the shell if any.
+func (state *completeState) write(candidates []string, prefix string, directive string) error {
+	b := strings.Builder{}
+	for _, candidate := range candidates {
+		name := candidate
+		if i := strings.IndexByte(name, '\t'); i >= 0 {
+			name = name[:i]
+		}
+		if strings.HasPrefix(name, prefix) {
+			fmt.Fprintln(&b, candidate)
+		}
+	}
+	if directive != "" {
+		fmt.Fprintln(&b, directive)
+	}
+	return state.Out.WriteFile(completeCommand, []byte(b.String()))
+}
+
+// Complete runs f to complete the last of the Context arguments, writing the
+// candidates to w. Commands are resolved through their CommandSet and the
+// arguments are parsed by Context.Parse, which returns before the rest of
+// the Function is run.
+func Complete(ctx *Context, f Function, w io.Writer) error {
+	if err := f(ctx.with(completeKey{}, &completeState{WriterOutput(w)})); err != nil && err != errComplete {
+		return err
+	}
+	return nil
+}
+
+// SetCompleter sets the Completer for the optional argument with the given
+// long name.
+func (opt *Optional) SetCompleter(long string, c Completer) {
+	arg, ok := opt.Args[long]
+	if !ok {
+		panic(fmt.Errorf("optional argument with long name %q does not exist", long))
+	}
+	arg.Completer = c
+	opt.Args[long] = arg
+}
+
+// SetCompleter sets the Completer for the positional argument with the given
+// name.
+func (pos *Positional) SetCompleter(name string, c Completer) {
+	arg, ok := pos.Args[name]
+	if !ok {
+		panic(fmt.Errorf("positional argument with name %q does not exist", name))
+	}
+	arg.Completer = c
+	pos.Args[name] = arg
+}
+
+// SetHint sets the completion Hint for the optional argument with the given
+// long name.
+func (opt *Optional) SetHint(long string, hint Hint) {
+	arg, ok := opt.Args[long]
+	if !ok {
+		panic(fmt.Errorf("optional argument with long name %q does not exist", long))
+	}
+	arg.Hint = hint
+	opt.Args[long] = arg
+}
+
+// SetHint sets the completion Hint for the positional argument with the given
+// name.
+func (pos *Positional) SetHint(name string, hint Hint) {
+	arg, ok := pos.Args[name]
+	if !ok {
+		panic(fmt.Errorf("positional argument with name %q does not exist", name))
+	}
+	arg.Hint = hint
+	pos.Args[name] = arg
+}
+
+// hintOf returns the completion Hint of the given argument. A Completer set
+// on the argument takes precedence over the hint of the Value.
+func hintOf(arg Argument) Hint {
+	if arg.Hint.Type != HintDefault || arg.Completer != nil {
+		return arg.Hint
+	}
+	if h, ok := arg.Value.(Hinter); ok {
+		return h.Hint()
+	}
+	return Hint{}
+}
+
+func takesValue(arg Argument) bool {
+	_, ok := arg.Value.(SwitchValue)
+	return !ok
+}
+
+// completeArg lists the candidates for the given argument, and the directive
+// for the shell to complete file or directory names instead.
+func completeArg(arg Argument, prefix string) ([]string, string) {
+	c := arg.Completer
+	if c == nil {
+		c, _ = arg.Value.(Completer)
+	}
+	switch hint := hintOf(arg); {
+	case hint.Type == HintFiles:
+		return nil, completeFiles
+	case hint.Type == HintDirs:
+		return nil, completeDirs
+	case hint.Type == HintWords:
+		return hint.Words, ""
+	case hint.Type == HintNone:
+		return nil, ""
+	case c != nil:
+		return c.Complete(prefix), ""
+	default:
+		return nil, completeFiles
+	}
+}
+
+// completeHelp returns the candidates for the builtin flags.
+func completeHelp(opt *Optional) []string {
+	candidates := []string{}
+	for _, flag := range opt.builtinFlags() {
+		for _, name := range flag.Names {
+			candidates = append(candidates, name+"\t"+flag.Usage)
+		}
+	}
+	return candidates
+}
+
+// complete writes the candidates for the last of the Context arguments. The
+// words before it are parsed as the command line would be, to find the flag
+// awaiting a value or the number of positional arguments given.
+func (ctx Context) complete(state *completeState, pos *Positional, opt *Optional, mode parseMode) error {
+	words, partial := ctx.Args, ""
+	if n := len(words); n > 0 {
+		words, partial = words[:n-1], words[n-1]
+	}
+
+	mode.Complete = true
+	extra, _, err := parseFlags(pos, opt, words, mode)
+	var pending *MissingValueError
+	errors.As(err, &pending)
+
+	count, terminated := len(extra), false
+	for _, word := range words {
+		terminated = terminated || word == "--"
+	}
+
+	candidates, directive := []string{}, ""
+
+	switch {
+	case pending != nil:
+		candidates, directive = completeArg(opt.Args[pending.Name], partial)
+
+	case !terminated && strings.HasPrefix(partial, "--") && strings.Contains(partial, "="):
+		i := strings.IndexByte(partial, '=')
+		if long, err := opt.lookup(partial[2:i], mode.Prefix); err == nil {
+			values, _ := completeArg(opt.Args[long], partial[i+1:])
+			for _, value := range values {
+				candidates = append(candidates, partial[:i+1]+value)
+			}
+		}
+
+	case !terminated && strings.HasPrefix(partial, "-"):
+		candidates = append(candidates, completeHelp(opt)...)
+		for _, long := range opt.longNames() {
+			candidates = append(candidates, fmt.Sprintf("--%s\t%s", long, opt.Args[long].Usage))
+			if opt.negatable(long) {
+				candidates = append(candidates, fmt.Sprintf("--no-%s\t%s", long, negatedUsage(long)))
+			}
+		}
+
+	default:
+		var arg Argument
+		switch {
+		case count < len(pos.Order):
+			arg = pos.Args[pos.Order[count]]
+		case pos.HasExtra():
+			arg = pos.Args[pos.Order[len(pos.Order)-1]]
+		}
+		if arg.Value != nil {
+			candidates, directive = completeArg(arg, partial)
+		} else {
+			directive = completeFiles
+		}
+	}
+
+	if err := state.write(candidates, partial, directive); err != nil {
+		return err
+	}
+	return errComplete
+}
+
+func (set CommandSet) complete(ctx *Context, state *completeState) error {
+	args := ctx.Args
+	for len(args) > 1 && strings.HasPrefix(args[0], "-") {
+		args = args[1:]
+	}
+
+	if len(args) <= 1 {
+		partial, candidates := "", []string{}
+		if len(args) == 1 {
+			partial = args[0]
+		}
+		if strings.HasPrefix(partial, "-") {
+			candidates = completeHelp(set.optional(ctx))
+		} else {
+			for _, name := range set.Commands() {
+				candidates = append(candidates, fmt.Sprintf("%s\t%s", name, set[name].Desc))
+			}
+		}
+		if err := state.write(candidates, partial, ""); err != nil {
+			return err
+		}
+		return errComplete
+	}
+
+	name, _ := match(args[0], set.Commands(), ctx.prefixMatching())
+	if name == "" {
+		return errComplete
+	}
+
+	cmd := set[name]
+	return cmd.inspect(&Context{append(ctx.Name, name), cmd.Desc, args[1:], ctx.Ctx})
+}
+
+// completeRoot tests if the Context is the root command called back by the
+// completion scripts, with CompleteEnv set, and returns the Context to
+// complete. The candidates are
+// written to the Output given by WithOutput, or to the standard output.
+func completeRoot(ctx *Context) (*Context, bool) {
+	if len(ctx.Name) != 1 || os.Getenv(CompleteEnv) == "" || ctx.completing() != nil {
+		return nil, false
+	}
+	out, ok := ctx.lookup(outputKey{}).(Output)
+	if !ok {
+		out = WriterOutput(os.Stdout)
+	}
+	return ctx.with(completeKey{}, &completeState{out}), true
+}
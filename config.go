@@ -0,0 +1,402 @@
+package flags
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Config represents the argument values read from a configuration file. The
+// values are keyed by section and long name, where the section of a command
+// is its name without the program name joined by periods.
+type Config map[string]map[string][]string
+
+// Section returns the values for the command with the given name.
+func (cfg Config) Section(name []string) map[string][]string {
+	return cfg[sectionOf(name)]
+}
+
+// sectionOf returns the section of the command with the given name.
+func sectionOf(name []string) string {
+	if len(name) == 0 {
+		return ""
+	}
+	return strings.Join(name[1:], ".")
+}
+
+func (cfg Config) add(section, key string, values ...string) {
+	if cfg[section] == nil {
+		cfg[section] = make(map[string][]string)
+	}
+	cfg[section][key] = append(cfg[section][key], values...)
+}
+
+// LoadConfig reads the configuration file with the given filename as JSON if
+// it has a `.json` extension, or as an INI or TOML-like file otherwise.
+func LoadConfig(filename string) (Config, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		return ReadJSONConfig(f)
+	}
+	return ReadINIConfig(f)
+}
+
+// ReadJSONConfig reads a configuration from a JSON object, where nested
+// objects are read as the sections of the subcommands.
+func ReadJSONConfig(r io.Reader) (Config, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	object := map[string]interface{}{}
+	if err := dec.Decode(&object); err != nil {
+		return nil, err
+	}
+
+	cfg := Config{}
+	if err := cfg.readJSON("", object); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (cfg Config) readJSON(section string, object map[string]interface{}) error {
+	for key, v := range object {
+		switch v := v.(type) {
+		case nil:
+		case map[string]interface{}:
+			if err := cfg.readJSON(joinSection(section, key), v); err != nil {
+				return err
+			}
+		case []interface{}:
+			values := make([]string, len(v))
+			for i, e := range v {
+				s, err := jsonString(e)
+				if err != nil {
+					return fmt.Errorf("invalid value for key %q: %v", key, err)
+				}
+				values[i] = s
+			}
+			cfg.add(section, key, values...)
+		default:
+			s, err := jsonString(v)
+			if err != nil {
+				return fmt.Errorf("invalid value for key %q: %v", key, err)
+			}
+			cfg.add(section, key, s)
+		}
+	}
+	return nil
+}
+
+func jsonString(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", v)
+	}
+}
+
+// ReadINIConfig reads a configuration from `key = value` lines grouped under
+// `[section]` headers. Values may be quoted and lists of values may be given
+// in brackets, as in TOML, and repeated keys are appended to.
+func ReadINIConfig(r io.Reader) (Config, error) {
+	cfg := Config{}
+	section := ""
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+		case line[0] == '[':
+			if line[len(line)-1] != ']' {
+				return nil, fmt.Errorf("line %d: unterminated section header", n)
+			}
+			name := strings.ReplaceAll(line[1:len(line)-1], ".", " ")
+			section = strings.Join(strings.Fields(name), ".")
+		default:
+			i := strings.IndexByte(line, '=')
+			if i < 0 {
+				return nil, fmt.Errorf("line %d: expected `key = value`", n)
+			}
+			key := strings.TrimSpace(line[:i])
+			values, err := iniValues(strings.TrimSpace(line[i+1:]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			cfg.add(section, key, values...)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func iniValues(s string) ([]string, error) {
+	if !strings.HasPrefix(s, "[") {
+		v, err := iniValue(s)
+		if err != nil {
+			return nil, err
+		}
+		return []string{v}, nil
+	}
+
+	if !strings.HasSuffix(s, "]") {
+		return nil, errors.New("unterminated list")
+	}
+
+	values := []string{}
+	for _, e := range splitList(s[1 : len(s)-1]) {
+		v, err := iniValue(e)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func iniValue(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, "\""):
+		return strconv.Unquote(s)
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		return s[1 : len(s)-1], nil
+	default:
+		return s, nil
+	}
+}
+
+// splitList splits a comma separated list, ignoring commas within quotes.
+func splitList(s string) []string {
+	list := []string{}
+	quote, escaped := rune(0), false
+	start := 0
+
+	for i, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0 && r == '\\' && quote == '"':
+			escaped = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == ',':
+			list = append(list, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		list = append(list, last)
+	}
+	return list
+}
+
+func joinSection(section, name string) string {
+	if section == "" {
+		return name
+	}
+	return section + "." + name
+}
+
+type configKey struct{}
+
+type configState struct {
+	Filename string
+	Loaded   bool
+	Checked  bool
+	Config   Config
+
+	// Set is the root CommandSet of the program, if any, whose commands
+	// are the only sections allowed in the configuration file.
+	Set CommandSet
+}
+
+func (state *configState) load(filename string, explicit bool) error {
+	if state.Loaded && state.Filename == filename {
+		return nil
+	}
+
+	state.Filename, state.Loaded, state.Checked, state.Config = filename, true, false, nil
+	if filename == "" {
+		return nil
+	}
+
+	cfg, err := LoadConfig(filename)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("while reading configuration file %s: %v", filename, err)
+	}
+	state.Config = cfg
+	return nil
+}
+
+// WithConfig returns a Function which runs f with argument values read from a
+// configuration file for every command. A `--config` flag naming the file is
+// added to each command, which defaults to the given filename and is ignored
+// if missing, and which the commands must not define themselves. The values
+// are used for the arguments given neither on the command line nor through
+// the environment.
+func WithConfig(filename string, f Function) Function {
+	return func(ctx *Context) error {
+		return f(ctx.with(configKey{}, &configState{Filename: filename}))
+	}
+}
+
+func (ctx Context) configState() *configState {
+	state, _ := ctx.lookup(configKey{}).(*configState)
+	return state
+}
+
+const configUsage = "configuration file to read argument values from"
+
+// registerConfig adds the `--config` flag to the optional arguments, unless
+// already added by a previous parse of the same arguments.
+func (ctx Context) registerConfig(opt *Optional) error {
+	state := ctx.configState()
+	if state == nil {
+		return nil
+	}
+	if opt.Args.Has("config") {
+		if opt.Args["config"].Usage != configUsage {
+			return fmt.Errorf("optional argument with long name %q is reserved for the configuration file", "config")
+		}
+		return nil
+	}
+	opt.String(0, "config", state.Filename, configUsage)
+	return nil
+}
+
+// check checks every section of the configuration file against the commands
+// of the program, given the optional arguments of the running command. The
+// root section belongs to the program made of a single command. The keys are
+// checked for the running command and for the commands registered with
+// Define, which are inspected without being run, while the other commands
+// check their own section once run.
+func (ctx Context) check(state *configState, opt *Optional) error {
+	if state.Checked {
+		return nil
+	}
+
+	section := sectionOf(ctx.Name)
+	sections := map[string]*Optional{section: opt}
+	known := map[string]bool{section: true}
+	if state.Set != nil {
+		err := state.Set.Walk(ctx.Name[:1], func(name []string, cmd Command) error {
+			s := sectionOf(name)
+			if s == section {
+				return nil
+			}
+			if _, opt, ok := cmd.Inspect(); ok {
+				if err := ctx.registerConfig(opt); err != nil {
+					return fmt.Errorf("%s: %v", strings.Join(name, " "), err)
+				}
+				sections[s] = opt
+			}
+			// The sections of the groups take no values.
+			known[s] = cmd.Sub == nil
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	names := make([]string, 0, len(state.Config))
+	for name := range state.Config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		values := state.Config[name]
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		opt, ok := sections[name]
+		for _, key := range keys {
+			switch {
+			case !known[name]:
+				return &UnknownFlagError{Long: key, Section: name, Source: state.Filename}
+			case ok && !opt.Args.Has(key):
+				return &UnknownFlagError{Long: key, Section: name, Source: state.Filename, Suggestions: suggest(key, opt.longNames())}
+			}
+		}
+	}
+
+	state.Checked = true
+	return nil
+}
+
+func (ctx Context) applyConfig(opt *Optional, seen map[string]source) error {
+	state := ctx.configState()
+	if state == nil {
+		return nil
+	}
+
+	filename := state.Filename
+	if arg, ok := opt.Args["config"]; ok {
+		filename = arg.Value.String()
+	}
+	if err := state.load(filename, seen["config"] != 0); err != nil {
+		return err
+	}
+
+	if err := ctx.check(state, opt); err != nil {
+		return err
+	}
+
+	values := state.Config.Section(ctx.Name)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		arg := opt.Args[key]
+		if seen[key] != 0 {
+			continue
+		}
+		for _, value := range values[key] {
+			if err := arg.Value.Set(value); err != nil {
+				return &InvalidValueError{Name: key, Value: value, Source: filename, Err: err}
+			}
+		}
+		seen[key] = sourceConfig
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package flags
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConstraintType represents the type of constraint on optional arguments.
+type ConstraintType int
+
+const (
+	// RequiredConstraint requires each of the arguments to be given.
+	RequiredConstraint ConstraintType = iota
+
+	// ExclusiveConstraint allows at most one of the arguments to be given.
+	ExclusiveConstraint
+
+	// AtLeastOneConstraint requires at least one of the arguments to be given.
+	AtLeastOneConstraint
+
+	// ImpliesConstraint requires the rest of the arguments to be given if the
+	// first argument is given.
+	ImpliesConstraint
+)
+
+// Constraint represents a constraint on the optional arguments given.
+type Constraint struct {
+	Type  ConstraintType
+	Names []string
+}
+
+func (opt *Optional) constrain(typ ConstraintType, names ...string) {
+	for _, name := range names {
+		if !opt.Args.Has(name) {
+			panic(fmt.Errorf("optional argument with long name %q does not exist", name))
+		}
+	}
+	opt.Constraints = append(opt.Constraints, Constraint{typ, names})
+}
+
+// Require requires the optional arguments with the given names to be given.
+func (opt *Optional) Require(names ...string) {
+	opt.constrain(RequiredConstraint, names...)
+}
+
+// Exclusive allows at most one of the optional arguments with the given names
+// to be given.
+func (opt *Optional) Exclusive(names ...string) {
+	opt.constrain(ExclusiveConstraint, names...)
+}
+
+// AtLeastOne requires at least one of the optional arguments with the given
+// names to be given.
+func (opt *Optional) AtLeastOne(names ...string) {
+	opt.constrain(AtLeastOneConstraint, names...)
+}
+
+// Implies requires the optional arguments with the given names to be given if
+// the optional argument with the name given first is.
+func (opt *Optional) Implies(name string, names ...string) {
+	opt.constrain(ImpliesConstraint, append([]string{name}, names...)...)
+}
+
+// Check adds a function to be called after parsing.
+func (opt *Optional) Check(f func() error) {
+	opt.Checks = append(opt.Checks, f)
+}
+
+func (opt *Optional) isRequired(long string) bool {
+	for _, c := range opt.Constraints {
+		if c.Type == RequiredConstraint {
+			for _, name := range c.Names {
+				if name == long {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (opt *Optional) implied(long string) []string {
+	names := []string{}
+	for _, c := range opt.Constraints {
+		if c.Type == ImpliesConstraint && c.Names[0] == long {
+			names = append(names, c.Names[1:]...)
+		}
+	}
+	return names
+}
+
+func (opt *Optional) synopsis(long string) string {
+	if _, ok := opt.Args[long].Value.(SwitchValue); ok {
+		return "--" + long
+	}
+	return fmt.Sprintf("--%s=%s", long, placeholder(long, opt.Args[long]))
+}
+
+func quoteNames(names []string) string {
+	list := make([]string, len(names))
+	for i, name := range names {
+		list[i] = fmt.Sprintf("%q", name)
+	}
+	return strings.Join(list, ", ")
+}
+
+// validate checks the constraints against the sources of the arguments given.
+// The exclusive and implying arguments are only those given on the command
+// line, so that values from the environment or a configuration file act as
+// defaults rather than conflicting with it.
+func (opt *Optional) validate(seen map[string]source) error {
+	for _, c := range opt.Constraints {
+		given := []string{}
+		for _, name := range c.Names {
+			if seen[name] == sourceArgs {
+				given = append(given, name)
+			}
+		}
+
+		switch c.Type {
+		case RequiredConstraint:
+			for _, name := range c.Names {
+				if seen[name] == 0 {
+					return &ConstraintError{RequiredConstraint, []string{name}}
+				}
+			}
+
+		case ExclusiveConstraint:
+			if len(given) > 1 {
+				return &ConstraintError{ExclusiveConstraint, given}
+			}
+
+		case AtLeastOneConstraint:
+			n := 0
+			for _, name := range c.Names {
+				if seen[name] != 0 {
+					n++
+				}
+			}
+			if n == 0 {
+				return &ConstraintError{AtLeastOneConstraint, c.Names}
+			}
+
+		case ImpliesConstraint:
+			if seen[c.Names[0]] != sourceArgs {
+				continue
+			}
+			for _, name := range c.Names[1:] {
+				if seen[name] == 0 {
+					return &ConstraintError{ImpliesConstraint, []string{c.Names[0], name}}
+				}
+			}
+		}
+	}
+
+	for _, f := range opt.Checks {
+		if err := f(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -38,64 +38,79 @@ func (ctx Context) Value(key interface{}) interface{} {
 	return ctx.Ctx.Value(key)
 }
 
+// with returns a copy of the Context carrying the given key-value pair.
+func (ctx Context) with(key, value interface{}) *Context {
+	parent := ctx.Ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	return &Context{ctx.Name, ctx.Desc, ctx.Args, context.WithValue(parent, key, value)}
+}
+
+// lookup returns the value carried by the Context for the given key.
+func (ctx Context) lookup(key interface{}) interface{} {
+	if ctx.Ctx == nil {
+		return nil
+	}
+	return ctx.Ctx.Value(key)
+}
+
 // Parse will parse the Context arguments based on the given positional and
 // optional argument definition objects.
 func (ctx *Context) Parse(pos *Positional, opt *Optional) error {
-	args, err := Parse(pos, opt, ctx.Args)
-	if err != nil {
-		b := strings.Builder{}
-		name := ctx.JoinedName()
-		usage := wrap.Space(Usage(pos, opt), 72-len(name))
-
-		switch err {
-		case errHelp:
-			b.WriteString(fmt.Sprintf("%s: %s\n\n", name, ctx.Desc))
-			b.WriteString(fmt.Sprintf("usage: %s %s\n", name, usage))
-			b.WriteString(Help(pos, opt))
-
-		case errRonn:
-			if err := Ronn(ctx, pos, opt); err != nil {
-				return ctx.Raise(err)
-			}
-			return errRonn
-
-		case errComp:
-			if len(ctx.Name) == 1 {
-				bash := fmt.Sprintf("%s-completion.bash", ctx.Name[0])
-				if err := touch(bash); err != nil {
-					return fmt.Errorf("while generating completion for %s: %v", ctx.Name[0], err)
-				}
-
-				zsh := fmt.Sprintf("%s-completion.zsh", ctx.Name[0])
-				if err := touch(zsh); err != nil {
-					return fmt.Errorf("while generating completion for %s: %v", ctx.Name[0], err)
-				}
-
-				zcomp := fmt.Sprintf("#compdef %s\n\n", ctx.Name[0])
-				if err := fileAppend(zsh, zcomp); err != nil {
-					return fmt.Errorf("while generating completion for %s: %v", ctx.JoinedName(), err)
-				}
-			}
+	env := opt.envNames(ctx.Name)
+	if help, ok := ctx.lookup(helpKey{}).(HelpFlags); ok {
+		opt.Help = help
+	}
+	opt.Version = ctx.versionFlags()
+	if err := ctx.registerConfig(opt); err != nil {
+		return err
+	}
 
-			if err := Comp(ctx, pos, opt); err != nil {
-				return ctx.Raise(err)
-			}
+	if child, ok := completeRoot(ctx); ok {
+		*ctx = *child
+	}
+	mode := parseMode{Prefix: opt.AllowPrefix || ctx.prefixMatching()}
+	if state := ctx.completing(); state != nil {
+		return ctx.complete(state, pos, opt, mode)
+	}
 
-			if len(ctx.Name) == 1 {
-				bash := fmt.Sprintf("%s-completion.bash", ctx.Name[0])
-				bcomp := fmt.Sprintf("complete -F _%[1]s %[1]s", ctx.Name[0])
-				if err := fileAppend(bash, bcomp); err != nil {
-					return fmt.Errorf("while generating completion for %s: %v", ctx.JoinedName(), err)
-				}
-			}
+	if child, ok := toolEnv(ctx); ok {
+		*ctx = *child
+	}
+	if tool, ok := ctx.tool(); ok {
+		return tool.run(ctx, pos, opt)
+	}
 
-			return errComp
+	// Values are taken from the command line, the environment and then the
+	// configuration file, in order of precedence.
+	args, seen, err := parseFlags(pos, opt, ctx.Args, mode)
+	if err == nil {
+		err = opt.applyEnv(env, seen)
+	}
+	if err == nil {
+		err = ctx.applyConfig(opt, seen)
+	}
+	if err == nil {
+		args, err = parsePositional(pos, args)
+	}
+	if err == nil {
+		err = opt.validate(seen)
+	}
+	var request *VersionError
+	if errors.As(err, &request) {
+		request.Version, _ = ctx.version()
+		return request
+	}
+	if err != nil {
+		name := ctx.JoinedName()
+		usage := wrap.Space(Usage(pos, opt), 72-len(name))
+		e := &UsageError{err, name, ctx.Desc, fmt.Sprintf("usage: %s %s", name, usage), ""}
 
-		default:
-			b.WriteString(fmt.Sprintf("%v\n\nusage: %s %s", err, name, usage))
+		if err == ErrHelp {
+			e.Help = help(pos, opt, env)
 		}
-
-		return errors.New(b.String())
+		return e
 	}
 	ctx.Args = args
 	return nil
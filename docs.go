@@ -0,0 +1,230 @@
+package flags
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// docPage represents the reference documentation of a command.
+type docPage struct {
+	Name       []string
+	Desc       string
+	Usage      string
+	Positional [][2]string
+	Optional   [][2]string
+	Commands   [][2]string
+}
+
+func (page docPage) filename() string {
+	return strings.Join(page.Name, "-")
+}
+
+func docOf(ctx *Context, pos *Positional, opt *Optional) docPage {
+	env := opt.envNames(ctx.Name)
+	page := docPage{
+		Name:  append([]string(nil), ctx.Name...),
+		Desc:  ctx.Desc,
+		Usage: fmt.Sprintf("usage: %s %s", ctx.JoinedName(), Usage(pos, opt)),
+	}
+
+	for _, name := range pos.Order {
+		arg := pos.Args[name]
+		page.Positional = append(page.Positional, [2]string{helpPositional(name, arg), arg.Usage})
+	}
+
+	for _, optName := range opt.sortedNames() {
+		short, long := optName.Short, optName.Long
+		flag := opt.helpFlag(short, long)
+		page.Optional = append(page.Optional, [2]string{flag, opt.helpUsage(long, env)})
+	}
+
+	return page
+}
+
+func (set CommandSet) doc(ctx *Context) docPage {
+	page := docPage{Name: append([]string(nil), ctx.Name...), Desc: ctx.Desc, Usage: set.usage(ctx)}
+	for _, name := range set.Commands() {
+		page.Commands = append(page.Commands, [2]string{name, set[name].Desc})
+	}
+	return page
+}
+
+type docsKey struct{}
+
+// document adds the page of the command to the documentation, running f to
+// add the pages of the subcommands. The documentation is generated once the
+// pages of every command are added, and errDocs is returned.
+func (ctx *Context) document(page docPage, f Function) error {
+	pages, ok := ctx.lookup(docsKey{}).(*[]docPage)
+	if !ok {
+		pages = &[]docPage{}
+		ctx = ctx.with(docsKey{}, pages)
+	}
+
+	*pages = append(*pages, page)
+	if f != nil {
+		if err := f(ctx); err != nil {
+			return err
+		}
+	}
+
+	if ok {
+		return errDocs
+	}
+
+	err := ctx.generate(func(ctx *Context) error {
+		for _, page := range *pages {
+			filename := page.filename() + ".md"
+			ctx.create(filename)
+			ctx.write(filename, page.markdown())
+		}
+
+		ctx.create("index.md")
+		ctx.write("index.md", markdownIndex(*pages))
+
+		filename := fmt.Sprintf("%s.html", ctx.Name[0])
+		ctx.create(filename)
+		ctx.write(filename, htmlDocs(*pages))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return errDocs
+}
+
+func markdownCode(s string) string {
+	return "`" + strings.ReplaceAll(s, "|", "\\|") + "`"
+}
+
+func markdownText(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
+
+func markdownTable(header string, rows [][2]string, link func(string) string) string {
+	lines := []string{
+		fmt.Sprintf("| %s | Description |", header),
+		"| --- | --- |",
+	}
+	for _, row := range rows {
+		name := markdownCode(row[0])
+		if link != nil {
+			name = fmt.Sprintf("[%s](%s)", name, link(row[0]))
+		}
+		lines = append(lines, fmt.Sprintf("| %s | %s |", name, markdownText(row[1])))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (page docPage) markdown() string {
+	parts := []string{
+		"# " + strings.Join(page.Name, " "),
+		sentencify(page.Desc),
+		"```\n" + page.Usage + "\n```",
+	}
+
+	if len(page.Positional) > 0 {
+		parts = append(parts, "## Positional arguments", markdownTable("Argument", page.Positional, nil))
+	}
+
+	if len(page.Optional) > 0 {
+		parts = append(parts, "## Optional arguments", markdownTable("Flag", page.Optional, nil))
+	}
+
+	if len(page.Commands) > 0 {
+		link := func(name string) string {
+			return fmt.Sprintf("%s-%s.md", page.filename(), name)
+		}
+		parts = append(parts, "## Commands", markdownTable("Command", page.Commands, link))
+	}
+
+	if n := len(page.Name); n > 1 {
+		parent := page.Name[:n-1]
+		link := fmt.Sprintf("[%s](%s.md)", strings.Join(parent, " "), strings.Join(parent, "-"))
+		parts = append(parts, fmt.Sprintf("See also %s.", link))
+	}
+
+	return strings.Join(parts, "\n\n") + "\n"
+}
+
+func markdownIndex(pages []docPage) string {
+	lines := []string{}
+	for _, page := range pages {
+		indent := strings.Repeat("  ", len(page.Name)-1)
+		link := fmt.Sprintf("[%s](%s.md)", strings.Join(page.Name, " "), page.filename())
+		lines = append(lines, fmt.Sprintf("%s- %s: %s", indent, link, sentencify(page.Desc)))
+	}
+	title := fmt.Sprintf("# %s reference", pages[0].Name[0])
+	return title + "\n\n" + strings.Join(lines, "\n") + "\n"
+}
+
+func htmlTable(header string, rows [][2]string, link func(string) string) []string {
+	lines := []string{
+		"<table>",
+		fmt.Sprintf("<tr><th>%s</th><th>Description</th></tr>", header),
+	}
+	for _, row := range rows {
+		name := fmt.Sprintf("<code>%s</code>", html.EscapeString(row[0]))
+		if link != nil {
+			name = fmt.Sprintf("<a href=\"#%s\">%s</a>", link(row[0]), name)
+		}
+		lines = append(lines, fmt.Sprintf("<tr><td>%s</td><td>%s</td></tr>", name, html.EscapeString(row[1])))
+	}
+	return append(lines, "</table>")
+}
+
+func htmlDocs(pages []docPage) string {
+	title := html.EscapeString(fmt.Sprintf("%s reference", pages[0].Name[0]))
+	lines := []string{
+		"<!DOCTYPE html>",
+		"<html>",
+		"<head>",
+		"<meta charset=\"utf-8\">",
+		fmt.Sprintf("<title>%s</title>", title),
+		"</head>",
+		"<body>",
+		fmt.Sprintf("<h1>%s</h1>", title),
+		"<nav>",
+		"<ul>",
+	}
+
+	for _, page := range pages {
+		name := html.EscapeString(strings.Join(page.Name, " "))
+		lines = append(lines, fmt.Sprintf("<li><a href=\"#%s\">%s</a>: %s</li>", page.filename(), name, html.EscapeString(sentencify(page.Desc))))
+	}
+
+	lines = append(lines, "</ul>", "</nav>")
+
+	for _, page := range pages {
+		lines = append(lines,
+			fmt.Sprintf("<section id=\"%s\">", page.filename()),
+			fmt.Sprintf("<h2>%s</h2>", html.EscapeString(strings.Join(page.Name, " "))),
+			fmt.Sprintf("<p>%s</p>", html.EscapeString(sentencify(page.Desc))),
+			fmt.Sprintf("<pre><code>%s</code></pre>", html.EscapeString(page.Usage)),
+		)
+
+		if len(page.Positional) > 0 {
+			lines = append(lines, "<h3>Positional arguments</h3>")
+			lines = append(lines, htmlTable("Argument", page.Positional, nil)...)
+		}
+
+		if len(page.Optional) > 0 {
+			lines = append(lines, "<h3>Optional arguments</h3>")
+			lines = append(lines, htmlTable("Flag", page.Optional, nil)...)
+		}
+
+		if len(page.Commands) > 0 {
+			link := func(name string) string {
+				return fmt.Sprintf("%s-%s", page.filename(), name)
+			}
+			lines = append(lines, "<h3>Commands</h3>")
+			lines = append(lines, htmlTable("Command", page.Commands, link)...)
+		}
+
+		lines = append(lines, "</section>")
+	}
+
+	lines = append(lines, "</body>", "</html>")
+	return strings.Join(lines, "\n") + "\n"
+}
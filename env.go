@@ -0,0 +1,92 @@
+package flags
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SetEnv sets the environment variable used as a fallback for the optional
+// argument with the given long name.
+func (opt *Optional) SetEnv(long, name string) {
+	if !opt.Args.Has(long) {
+		panic(fmt.Errorf("optional argument with long name %q does not exist", long))
+	}
+	if opt.Env == nil {
+		opt.Env = make(map[string]string)
+	}
+	opt.Env[long] = name
+}
+
+// EnvName creates an environment variable name from the given name parts.
+func EnvName(parts ...string) string {
+	s := strings.ToUpper(strings.Join(parts, "_"))
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// envNames returns the environment variables of the optional arguments by
+// long name, along with the ones derived from the name of the command if
+// AutoEnv is set. The Env map is left as is.
+func (opt *Optional) envNames(name []string) map[string]string {
+	env := make(map[string]string, len(opt.Args))
+	for long, v := range opt.Env {
+		env[long] = v
+	}
+	if !opt.AutoEnv {
+		return env
+	}
+	for long := range opt.Args {
+		if _, ok := env[long]; !ok {
+			parts := append(append([]string{}, name...), long)
+			env[long] = EnvName(parts...)
+		}
+	}
+	return env
+}
+
+func (opt *Optional) applyEnv(env map[string]string, seen map[string]source) error {
+	sep := opt.EnvSep
+	if sep == "" {
+		sep = ","
+	}
+
+	longs := make([]string, 0, len(env))
+	for long := range env {
+		longs = append(longs, long)
+	}
+	sort.Strings(longs)
+
+	for _, long := range longs {
+		name := env[long]
+		s, ok := os.LookupEnv(name)
+		if !ok || seen[long] != 0 {
+			continue
+		}
+
+		arg, ok := opt.Args[long]
+		if !ok {
+			continue
+		}
+
+		values := []string{s}
+		if _, ok := arg.Value.(SliceValue); ok {
+			values = strings.Split(s, sep)
+		}
+
+		for _, value := range values {
+			if err := arg.Value.Set(value); err != nil {
+				return &InvalidValueError{Name: long, Value: value, Source: "$" + name, Err: err}
+			}
+		}
+		seen[long] = sourceEnv
+	}
+
+	return nil
+}
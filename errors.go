@@ -0,0 +1,205 @@
+package flags
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrHelp is returned when help is requested through the command line.
+var ErrHelp = errors.New("help")
+
+// ErrVersion is wrapped by the VersionError.
+var ErrVersion = errors.New("version")
+
+// UnknownFlagError is returned when an optional argument is not registered.
+// The Section and Source are set for the keys of a configuration file, and
+// the Suggestions are the long names of similar optional arguments.
+type UnknownFlagError struct {
+	Short       rune
+	Long        string
+	Section     string
+	Source      string
+	Suggestions []string
+}
+
+// Error satisfies the error interface.
+func (e *UnknownFlagError) Error() string {
+	msg := fmt.Sprintf("unknown short option `%c`", e.Short)
+	if e.Long != "" {
+		msg = fmt.Sprintf("unknown flag %q", e.Long)
+	}
+	if e.Section != "" {
+		msg = fmt.Sprintf("%s in section [%s]", msg, e.Section)
+	}
+	if e.Source != "" {
+		msg = fmt.Sprintf("%s in %s", msg, e.Source)
+	}
+	return msg + didYouMean(e.Suggestions, "`--%s`")
+}
+
+// MissingValueError is returned when an optional argument is given without
+// the value it requires.
+type MissingValueError struct {
+	Name string
+}
+
+// Error satisfies the error interface.
+func (e *MissingValueError) Error() string {
+	return fmt.Sprintf("while setting value for flag %q: no value given", e.Name)
+}
+
+// InvalidValueError is returned when a value cannot be set to an argument.
+// The Source is empty for values given on the command line.
+type InvalidValueError struct {
+	Name       string
+	Value      string
+	Source     string
+	Positional bool
+	Err        error
+}
+
+// Error satisfies the error interface.
+func (e *InvalidValueError) Error() string {
+	kind := "flag"
+	if e.Positional {
+		kind = "positional argument"
+	}
+	if e.Source != "" {
+		return fmt.Sprintf("while setting value for %s %q from %s: %v", kind, e.Name, e.Source, e.Err)
+	}
+	return fmt.Sprintf("while setting value for %s %q: %v", kind, e.Name, e.Err)
+}
+
+// Unwrap returns the error returned by Value.Set.
+func (e *InvalidValueError) Unwrap() error {
+	return e.Err
+}
+
+// MissingPositionalError is returned when positional arguments are missing.
+type MissingPositionalError struct {
+	Names []string
+}
+
+// Error satisfies the error interface.
+func (e *MissingPositionalError) Error() string {
+	return fmt.Sprintf("missing positional arguments(s): %s", quoteNames(e.Names))
+}
+
+// ConstraintError is returned when a Constraint on the optional arguments is
+// not satisfied. The Names are the long names of the arguments missing for a
+// RequiredConstraint, given together for an ExclusiveConstraint, constrained
+// for an AtLeastOneConstraint, and the implying argument followed by the one
+// missing for an ImpliesConstraint.
+type ConstraintError struct {
+	Type  ConstraintType
+	Names []string
+}
+
+// Error satisfies the error interface.
+func (e *ConstraintError) Error() string {
+	switch e.Type {
+	case RequiredConstraint:
+		return fmt.Sprintf("missing required flag %q", e.Names[0])
+	case ExclusiveConstraint:
+		return fmt.Sprintf("flags %s cannot be given together", quoteNames(e.Names))
+	case AtLeastOneConstraint:
+		return fmt.Sprintf("one of the flags %s must be given", quoteNames(e.Names))
+	default:
+		return fmt.Sprintf("flag %q requires flag %q", e.Names[0], e.Names[1])
+	}
+}
+
+// UnknownCommandError is returned when a command is not registered.
+// The Suggestions are the names of similar commands.
+type UnknownCommandError struct {
+	Name        string
+	Suggestions []string
+}
+
+// Error satisfies the error interface.
+func (e *UnknownCommandError) Error() string {
+	return fmt.Sprintf("unknown command name `%s`", e.Name) + didYouMean(e.Suggestions, "`%s`")
+}
+
+func didYouMean(suggestions []string, format string) string {
+	list := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		list[i] = fmt.Sprintf(format, s)
+	}
+	switch len(list) {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf(", did you mean %s?", list[0])
+	default:
+		return fmt.Sprintf(", did you mean one of %s?", strings.Join(list, ", "))
+	}
+}
+
+// UsageError wraps an error returned while parsing the arguments of a command
+// along with the usage of the command. The Help is only set for ErrHelp.
+type UsageError struct {
+	Err   error
+	Name  string
+	Desc  string
+	Usage string
+	Help  string
+}
+
+// Error satisfies the error interface.
+func (e *UsageError) Error() string {
+	if e.Err == ErrHelp {
+		return fmt.Sprintf("%s: %s\n\n%s\n%s", e.Name, e.Desc, e.Usage, e.Help)
+	}
+	return fmt.Sprintf("%v\n\n%s", e.Err, e.Usage)
+}
+
+// Unwrap returns the wrapped error.
+func (e *UsageError) Unwrap() error {
+	return e.Err
+}
+
+// VersionError is returned in place of running a command when its version is
+// requested. The message is the Version to be printed.
+type VersionError struct {
+	Version Version
+	JSON    bool
+}
+
+// Error satisfies the error interface.
+func (e *VersionError) Error() string {
+	v := e.Version
+	if e.JSON {
+		b, _ := json.MarshalIndent(struct {
+			Version   string `json:"version"`
+			Major     int    `json:"major"`
+			Minor     int    `json:"minor"`
+			Patch     int    `json:"patch"`
+			Pre       string `json:"pre,omitempty"`
+			Build     string `json:"build,omitempty"`
+			Commit    string `json:"commit,omitempty"`
+			Date      string `json:"date,omitempty"`
+			GoVersion string `json:"go,omitempty"`
+		}{v.String(), v.Major, v.Minor, v.Patch, v.Pre, v.Build, v.Commit, v.Date, v.GoVersion}, "", "  ")
+		return string(b)
+	}
+
+	lines := []string{v.String()}
+	if v.Commit != "" {
+		lines = append(lines, "commit: "+v.Commit)
+	}
+	if v.Date != "" {
+		lines = append(lines, "date: "+v.Date)
+	}
+	if v.GoVersion != "" {
+		lines = append(lines, "go: "+v.GoVersion)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Unwrap returns ErrVersion.
+func (e *VersionError) Unwrap() error {
+	return ErrVersion
+}
@@ -2,6 +2,7 @@ package flags
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 )
@@ -18,22 +19,31 @@ func Register(name, desc string, f Function) {
 	main.Register(name, desc, f)
 }
 
+// Define a command with the given arguments in the main CommandSet.
+func Define(name, desc string, def Definition) {
+	main.Define(name, desc, def)
+}
+
+// Group the given commands under a command in the main CommandSet.
+func Group(name, desc string, sub CommandSet) {
+	main.Group(name, desc, sub)
+}
+
 // Compile the main CommandSet.
 func Compile() Function {
 	return main.Compile()
 }
 
-// Run the given Function.
+// Run the given Function, declaring the Version for the root command along
+// with the build information of the binary.
 func Run(name, desc string, version Version, f Function) int {
-	args := os.Args[1:]
-	for _, arg := range args {
-		if arg == "--version" {
-			fmt.Fprintln(os.Stdout, version)
+	ctx := &Context{[]string{name}, desc, os.Args[1:], context.Background()}
+	f = WithVersion(version.BuildInfo(), f)
+	if err := f(ctx); err != nil && !generated(err) {
+		if errors.Is(err, ErrHelp) || errors.Is(err, ErrVersion) {
+			fmt.Fprintln(os.Stdout, err)
 			return 0
 		}
-	}
-	ctx := &Context{[]string{name}, desc, args, context.Background()}
-	if err := f(ctx); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		return 1
 	}
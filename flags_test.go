@@ -1,9 +1,19 @@
 package flags
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 )
 
 func same(a, b interface{}) bool {
@@ -79,3 +89,1822 @@ func TestPositional(t *testing.T) {
 		return
 	}
 }
+
+func TestParseLongValue(t *testing.T) {
+	pos, opt := Flags()
+	verbose := opt.Switch('v', "verbose", "verbose output")
+	count := opt.Int('n', "count", 1, "number of iterations")
+	name := opt.String(0, "name", "default", "name to use")
+	tags := opt.StringSlice(0, "tag", nil, "tags to apply")
+	ints := opt.IntSlice(0, "int", nil, "integers to use")
+
+	args := []string{
+		"--verbose=true",
+		"--count=42",
+		"--name=",
+		"--tag=a",
+		"--tag=b",
+		"--int=-1",
+		"--int=2",
+		"--verbose=false",
+	}
+
+	extra, err := Parse(pos, opt, args)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", args, err)
+	}
+
+	equals(t, len(extra), 0)
+	equals(t, *verbose, false)
+	equals(t, *count, 42)
+	equals(t, *name, "")
+	equals(t, *tags, []string{"a", "b"})
+	equals(t, *ints, []int{-1, 2})
+
+	if _, err := Parse(pos, opt, []string{"--count=foo"}); err == nil {
+		t.Error("Parse([--count=foo]) = nil, want error")
+	}
+
+	if _, err := Parse(pos, opt, []string{"--unknown=foo"}); err == nil {
+		t.Error("Parse([--unknown=foo]) = nil, want error")
+	}
+}
+
+func TestParseShortBundle(t *testing.T) {
+	pos, opt := Flags()
+	verbose := opt.Switch('v', "verbose", "verbose output")
+	extended := opt.Switch('x', "extended", "extended output")
+	count := opt.Int('n', "count", 1, "number of iterations")
+	output := opt.String('o', "output", "", "output file")
+	tags := opt.StringSlice('t', "tag", nil, "tags to apply")
+
+	args := []string{"-n5", "-vxo", "out.txt", "-ta", "-t=b"}
+	extra, err := Parse(pos, opt, args)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", args, err)
+	}
+
+	equals(t, len(extra), 0)
+	equals(t, *verbose, true)
+	equals(t, *extended, true)
+	equals(t, *count, 5)
+	equals(t, *output, "out.txt")
+	equals(t, *tags, []string{"a", "b"})
+
+	args = []string{"-o=foo", "-vofile", "-x=false"}
+	if _, err := Parse(pos, opt, args); err != nil {
+		t.Fatalf("Parse(%q): %v", args, err)
+	}
+
+	equals(t, *output, "file")
+	equals(t, *extended, false)
+
+	if _, err := Parse(pos, opt, []string{"-vn"}); err == nil {
+		t.Error("Parse([-vn]) = nil, want error")
+	}
+
+	if _, err := Parse(pos, opt, []string{"-nfoo"}); err == nil {
+		t.Error("Parse([-nfoo]) = nil, want error")
+	}
+}
+
+func TestBind(t *testing.T) {
+	type database struct {
+		Host string `flag:"host" usage:"database host" default:"localhost"`
+		Port int    `flag:"port" usage:"database port" default:"5432"`
+	}
+
+	config := struct {
+		Verbose bool     `flag:"v,verbose" usage:"verbose output"`
+		Count   int      `flag:"n,count" usage:"number of iterations" default:"3"`
+		Tags    []string `flag:"tag" usage:"tags to apply" default:"a,b"`
+		Input   string   `pos:"1" usage:"input file"`
+		Rest    []string `pos:"2,files" usage:"extra files"`
+		DB      database `flag:"db"`
+		ignored int
+	}{}
+
+	pos, opt := Flags()
+	if err := Bind(pos, opt, &config); err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+
+	equals(t, pos.Order, []string{"input", "files"})
+	equals(t, opt.Alias, map[rune]string{'v': "verbose", 'n': "count"})
+	equals(t, opt.Args.Has("db-host"), true)
+	equals(t, opt.Args.Has("db-port"), true)
+	equals(t, config.Count, 3)
+	equals(t, config.Tags, []string{"a", "b"})
+	equals(t, config.DB.Host, "localhost")
+
+	args := []string{"-vn5", "--db-port=3306", "in.txt", "x", "y"}
+	if _, err := Parse(pos, opt, args); err != nil {
+		t.Fatalf("Parse(%q): %v", args, err)
+	}
+
+	equals(t, config.Verbose, true)
+	equals(t, config.Count, 5)
+	equals(t, config.Input, "in.txt")
+	equals(t, config.Rest, []string{"x", "y"})
+	equals(t, config.DB.Port, 3306)
+
+	if err := Bind(pos, opt, config); err == nil {
+		t.Error("Bind(struct) = nil, want error")
+	}
+
+	bad := struct {
+		C complex128 `flag:"c"`
+	}{}
+	if err := Bind(pos, opt, &bad); err == nil {
+		t.Error("Bind(complex128) = nil, want error")
+	}
+
+	// A conflicting field leaves the argument lists untouched.
+	dup := struct {
+		Debug   bool   `flag:"d,debug"`
+		Verbose bool   `flag:"verbose"`
+		Output  string `pos:"1,output"`
+	}{}
+	n, order := len(opt.Args), len(pos.Order)
+	if err := Bind(pos, opt, &dup); err == nil {
+		t.Error("Bind(duplicate) = nil, want error")
+	}
+	equals(t, len(opt.Args), n)
+	equals(t, len(pos.Order), order)
+	equals(t, opt.Args.Has("debug"), false)
+
+	twice := struct {
+		A string `flag:"a,name"`
+		B string `flag:"b,name"`
+	}{}
+	pos, opt = Flags()
+	if err := Bind(pos, opt, &twice); err == nil {
+		t.Error("Bind(twice) = nil, want error")
+	}
+	equals(t, len(opt.Args), 0)
+}
+
+func TestEnv(t *testing.T) {
+	pos, opt := Flags()
+	port := opt.Int('p', "port", 80, "port to listen on")
+	hosts := opt.StringSlice(0, "host", nil, "hosts to serve")
+	debug := opt.Switch(0, "debug", "debug output")
+	opt.SetEnv("debug", "TEST_DEBUG")
+	opt.AutoEnv = true
+	opt.EnvSep = ":"
+
+	t.Setenv("MYTOOL_SERVE_PORT", "8080")
+	t.Setenv("MYTOOL_SERVE_HOST", "a:b")
+	t.Setenv("TEST_DEBUG", "true")
+
+	ctx := &Context{Name: []string{"mytool", "serve"}}
+	if err := ctx.Parse(pos, opt); err != nil {
+		t.Fatalf("ctx.Parse: %v", err)
+	}
+
+	equals(t, opt.envNames(ctx.Name)["port"], "MYTOOL_SERVE_PORT")
+	_, derived := opt.Env["port"]
+	equals(t, derived, false)
+	equals(t, *port, 8080)
+	equals(t, *hosts, []string{"a", "b"})
+	equals(t, *debug, true)
+
+	*hosts = nil
+	ctx.Args = []string{"--port=9090", "--host", "c"}
+	if err := ctx.Parse(pos, opt); err != nil {
+		t.Fatalf("ctx.Parse: %v", err)
+	}
+
+	equals(t, *port, 9090)
+	equals(t, *hosts, []string{"c"})
+
+	t.Setenv("MYTOOL_SERVE_PORT", "foo")
+	ctx.Args = nil
+	if err := ctx.Parse(pos, opt); err == nil {
+		t.Error("ctx.Parse() = nil, want error")
+	}
+
+	// The variables are read in the order of the long names, so that the
+	// same error is reported every time.
+	t.Setenv("TEST_DEBUG", "maybe")
+	for i := 0; i < 10; i++ {
+		var invalid *InvalidValueError
+		if err := ctx.Parse(pos, opt); !errors.As(err, &invalid) {
+			t.Fatalf("ctx.Parse() = %v, want InvalidValueError", err)
+		}
+		equals(t, invalid.Name, "debug")
+	}
+}
+
+func TestConfig(t *testing.T) {
+	ini := strings.Join([]string{
+		"# global settings",
+		"[serve]",
+		"port = 8080",
+		"host = [\"a\", 'b']",
+		"name = \"foo bar\"",
+		"debug = true",
+	}, "\n")
+
+	cfg, err := ReadINIConfig(strings.NewReader(ini))
+	if err != nil {
+		t.Fatalf("ReadINIConfig: %v", err)
+	}
+
+	equals(t, cfg.Section([]string{"mytool", "serve"}), map[string][]string{
+		"port":  {"8080"},
+		"host":  {"a", "b"},
+		"name":  {"foo bar"},
+		"debug": {"true"},
+	})
+
+	js := `{"verbose": true, "serve": {"port": 8080, "host": ["a", "b"]}}`
+	cfg, err = ReadJSONConfig(strings.NewReader(js))
+	if err != nil {
+		t.Fatalf("ReadJSONConfig: %v", err)
+	}
+
+	equals(t, cfg, Config{
+		"":      {"verbose": {"true"}},
+		"serve": {"port": {"8080"}, "host": {"a", "b"}},
+	})
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "mytool.json")
+	if err := os.WriteFile(filename, []byte(`{"serve": {"port": 8080, "host": ["a", "b"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var port *int
+	var hosts *[]string
+	serve := func(ctx *Context) error {
+		pos, opt := Flags()
+		port = opt.Int('p', "port", 80, "port to listen on")
+		hosts = opt.StringSlice(0, "host", nil, "hosts to serve")
+		return ctx.Parse(pos, opt)
+	}
+
+	set := CommandSet{}
+	set.Register("serve", "serve files", serve)
+	set.Define("status", "show status", func(pos *Positional, opt *Optional) Function {
+		opt.Switch(0, "short", "give the output in the short format")
+		return func(ctx *Context) error { return nil }
+	})
+	f := WithConfig(filepath.Join(dir, "missing.json"), set.Compile())
+
+	run := func(args ...string) error {
+		return f(&Context{Name: []string{"mytool"}, Args: args})
+	}
+
+	if err := run("serve"); err != nil {
+		t.Fatalf("run(serve): %v", err)
+	}
+	equals(t, *port, 80)
+
+	if err := run("--config", filename, "serve", "--port", "9090"); err != nil {
+		t.Fatalf("run(--config %s serve --port 9090): %v", filename, err)
+	}
+	equals(t, *port, 9090)
+	equals(t, *hosts, []string{"a", "b"})
+
+	if err := run("serve", "--config="+filename); err != nil {
+		t.Fatalf("run(serve --config=%s): %v", filename, err)
+	}
+	equals(t, *port, 8080)
+
+	if err := os.WriteFile(filename, []byte(`{"serve": {"prot": 1}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run("serve", "--config", filename); err == nil {
+		t.Error("run(serve --config) with unknown key = nil, want error")
+	}
+
+	// Every section is checked against the commands, and the root section
+	// takes no values in a program made of a CommandSet.
+	for _, c := range []struct {
+		config string
+		want   UnknownFlagError
+	}{
+		{`{"verbose": true, "serve": {"port": 1}}`, UnknownFlagError{Long: "verbose"}},
+		{`{"srve": {"port": 1}}`, UnknownFlagError{Long: "port", Section: "srve"}},
+		{`{"serve": {"port": 1}, "status": {"shrt": true}}`, UnknownFlagError{Long: "shrt", Section: "status", Suggestions: []string{"short"}}},
+	} {
+		if err := os.WriteFile(filename, []byte(c.config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var e *UnknownFlagError
+		if err := run("--config", filename, "serve"); !errors.As(err, &e) {
+			t.Errorf("run(--config serve) with %s = %v, want UnknownFlagError", c.config, err)
+			continue
+		}
+		c.want.Source = filename
+		equals(t, *e, c.want)
+	}
+
+	var e *MissingValueError
+	if err := run("--config"); !errors.As(err, &e) {
+		t.Errorf("run(--config) = %v, want MissingValueError", err)
+	}
+
+	// Values from the file act as defaults for the flags exclusive with the
+	// ones given on the command line.
+	var json, yaml *bool
+	set.Register("render", "render output", func(ctx *Context) error {
+		pos, opt := Flags()
+		json = opt.Switch(0, "json", "output json")
+		yaml = opt.Switch(0, "yaml", "output yaml")
+		opt.Exclusive("json", "yaml")
+		return ctx.Parse(pos, opt)
+	})
+	if err := os.WriteFile(filename, []byte(`{"render": {"json": true}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run("render", "--config", filename, "--yaml"); err != nil {
+		t.Fatalf("run(render --config --yaml): %v", err)
+	}
+	equals(t, *json, true)
+	equals(t, *yaml, true)
+	if err := run("render", "--config", filename, "--json", "--yaml"); err == nil {
+		t.Error("run(render --json --yaml) = nil, want error")
+	}
+
+	err = WithConfig(filename, func(ctx *Context) error {
+		pos, opt := Flags()
+		opt.String('c', "config", "", "configuration to use")
+		return ctx.Parse(pos, opt)
+	})(&Context{Name: []string{"mytool"}})
+	if err == nil {
+		t.Error("Parse with a config flag defined = nil, want error")
+	}
+
+	// The same arguments may be parsed more than once.
+	pos, opt := Flags()
+	f = WithConfig("", func(ctx *Context) error {
+		return ctx.Parse(pos, opt)
+	})
+	for i := 0; i < 2; i++ {
+		if err := f(&Context{Name: []string{"mytool"}}); err != nil {
+			t.Fatalf("Parse #%d: %v", i+1, err)
+		}
+	}
+}
+
+func TestConstraints(t *testing.T) {
+	pos, opt := Flags()
+	opt.String('n', "name", "", "name to use")
+	opt.Switch(0, "json", "output json")
+	opt.Switch(0, "yaml", "output yaml")
+	opt.String(0, "cert", "", "certificate file")
+	opt.String(0, "key", "", "key file")
+	opt.Require("name")
+	opt.Exclusive("json", "yaml")
+	opt.Implies("cert", "key")
+
+	checked := false
+	opt.Check(func() error {
+		checked = true
+		return nil
+	})
+
+	cases := []struct {
+		args []string
+		ok   bool
+	}{
+		{[]string{"-n", "foo"}, true},
+		{[]string{"--json"}, false},
+		{[]string{"-n", "foo", "--json", "--yaml"}, false},
+		{[]string{"-n", "foo", "--cert", "a"}, false},
+		{[]string{"-n", "foo", "--cert", "a", "--key", "b"}, true},
+	}
+
+	for _, c := range cases {
+		_, err := Parse(pos, opt, c.args)
+		if (err == nil) != c.ok {
+			t.Errorf("Parse(%q) = %v", c.args, err)
+		}
+	}
+
+	equals(t, checked, true)
+	equals(t, Usage(pos, opt), "[-h | --help] [<args>] --name=<name> [--json | --yaml]")
+
+	panics(t, func() { opt.Require("unknown") })
+}
+
+func TestErrors(t *testing.T) {
+	pos, opt := Flags()
+	pos.String("file", "file to read")
+	opt.Int('n', "count", 1, "number of iterations")
+
+	var unknown *UnknownFlagError
+	_, err := Parse(pos, opt, []string{"--foo"})
+	if !errors.As(err, &unknown) || unknown.Long != "foo" {
+		t.Errorf("Parse([--foo]) = %v, want UnknownFlagError", err)
+	}
+
+	_, err = Parse(pos, opt, []string{"-x"})
+	if !errors.As(err, &unknown) || unknown.Short != 'x' {
+		t.Errorf("Parse([-x]) = %v, want UnknownFlagError", err)
+	}
+
+	var missing *MissingValueError
+	_, err = Parse(pos, opt, []string{"--count"})
+	if !errors.As(err, &missing) || missing.Name != "count" {
+		t.Errorf("Parse([--count]) = %v, want MissingValueError", err)
+	}
+
+	var invalid *InvalidValueError
+	_, err = Parse(pos, opt, []string{"--count", "foo"})
+	if !errors.As(err, &invalid) || invalid.Value != "foo" || invalid.Err == nil {
+		t.Errorf("Parse([--count foo]) = %v, want InvalidValueError", err)
+	}
+
+	var positional *MissingPositionalError
+	_, err = Parse(pos, opt, nil)
+	if !errors.As(err, &positional) {
+		t.Errorf("Parse(nil) = %v, want MissingPositionalError", err)
+	}
+	equals(t, positional.Names, []string{"file"})
+
+	_, cons := Flags()
+	cons.Switch(0, "json", "output json")
+	cons.Switch(0, "yaml", "output yaml")
+	cons.String(0, "cert", "", "certificate file")
+	cons.String(0, "key", "", "key file")
+	cons.Require("key")
+	cons.Exclusive("json", "yaml")
+	cons.AtLeastOne("json", "yaml")
+	cons.Implies("cert", "key")
+
+	var constraint *ConstraintError
+	for _, c := range []struct {
+		args []string
+		want ConstraintError
+		msg  string
+	}{
+		{nil, ConstraintError{RequiredConstraint, []string{"key"}}, `missing required flag "key"`},
+		{[]string{"--key=a", "--json", "--yaml"}, ConstraintError{ExclusiveConstraint, []string{"json", "yaml"}}, `flags "json", "yaml" cannot be given together`},
+		{[]string{"--key=a"}, ConstraintError{AtLeastOneConstraint, []string{"json", "yaml"}}, `one of the flags "json", "yaml" must be given`},
+	} {
+		_, err = Parse(newPositional(), cons, c.args)
+		if !errors.As(err, &constraint) {
+			t.Fatalf("Parse(%q) = %v, want ConstraintError", c.args, err)
+		}
+		equals(t, *constraint, c.want)
+		equals(t, err.Error(), c.msg)
+	}
+
+	_, cons = Flags()
+	cons.String(0, "cert", "", "certificate file")
+	cons.String(0, "key", "", "key file")
+	cons.Implies("cert", "key")
+	_, err = Parse(newPositional(), cons, []string{"--cert", "a"})
+	if !errors.As(err, &constraint) {
+		t.Fatalf("Parse([--cert a]) = %v, want ConstraintError", err)
+	}
+	equals(t, *constraint, ConstraintError{ImpliesConstraint, []string{"cert", "key"}})
+	equals(t, err.Error(), `flag "cert" requires flag "key"`)
+
+	ctx := &Context{Name: []string{"mytool"}, Desc: "test tool", Args: []string{"-h"}}
+	err = ctx.Parse(pos, opt)
+	if !errors.Is(err, ErrHelp) {
+		t.Errorf("ctx.Parse([-h]) = %v, want ErrHelp", err)
+	}
+
+	var usage *UsageError
+	ctx.Args = []string{"--foo"}
+	err = ctx.Parse(pos, opt)
+	if !errors.As(err, &usage) || !errors.As(err, &unknown) {
+		t.Errorf("ctx.Parse([--foo]) = %v, want UsageError", err)
+	}
+	equals(t, usage.Usage, "usage: mytool "+Usage(pos, opt))
+
+	var command *UnknownCommandError
+	set := CommandSet{}
+	set.Register("serve", "serve files", func(ctx *Context) error { return nil })
+	err = set.Compile()(&Context{Name: []string{"mytool"}, Args: []string{"srve"}})
+	if !errors.As(err, &command) || command.Name != "srve" {
+		t.Errorf("Compile()([srve]) = %v, want UnknownCommandError", err)
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	pos, opt := Flags()
+	verbose := opt.Switch('v', "verbose", "verbose output")
+	opt.Switch(0, "version", "print the version")
+	opt.Switch(0, "debug", "debug output")
+
+	var unknown *UnknownFlagError
+	_, err := Parse(pos, opt, []string{"--verison"})
+	if !errors.As(err, &unknown) {
+		t.Fatalf("Parse([--verison]) = %v, want UnknownFlagError", err)
+	}
+	equals(t, unknown.Suggestions, []string{"version"})
+	equals(t, err.Error(), "unknown flag \"verison\", did you mean `--version`?")
+
+	_, err = Parse(pos, opt, []string{"--verbose"})
+	equals(t, err, nil)
+
+	if _, err := Parse(pos, opt, []string{"--verb"}); err == nil {
+		t.Error("Parse([--verb]) = nil, want error")
+	}
+
+	*verbose = false
+	opt.AllowPrefix = true
+	if _, err := Parse(pos, opt, []string{"--verb"}); err != nil {
+		t.Errorf("Parse([--verb]) = %v, want nil", err)
+	}
+	equals(t, *verbose, true)
+
+	_, err = Parse(pos, opt, []string{"--ver"})
+	if !errors.As(err, &unknown) {
+		t.Fatalf("Parse([--ver]) = %v, want UnknownFlagError", err)
+	}
+	equals(t, unknown.Suggestions, []string{"verbose", "version"})
+
+	set := CommandSet{}
+	called := ""
+	for _, name := range []string{"status", "stash", "commit"} {
+		name := name
+		set.Register(name, name, func(ctx *Context) error {
+			called = name
+			return nil
+		})
+	}
+
+	var command *UnknownCommandError
+	err = set.Compile()(&Context{Name: []string{"mytool"}, Args: []string{"stauts"}})
+	if !errors.As(err, &command) {
+		t.Fatalf("Compile()([stauts]) = %v, want UnknownCommandError", err)
+	}
+	equals(t, command.Suggestions, []string{"status"})
+
+	f := WithPrefixMatching(set.Compile())
+	if err := f(&Context{Name: []string{"mytool"}, Args: []string{"com"}}); err != nil {
+		t.Fatalf("WithPrefixMatching([com]) = %v", err)
+	}
+	equals(t, called, "commit")
+
+	pos, opt = Flags()
+	verbose = opt.Switch('v', "verbose", "verbose output")
+	f = WithPrefixMatching(func(ctx *Context) error {
+		return ctx.Parse(pos, opt)
+	})
+	if err := f(&Context{Name: []string{"mytool"}, Args: []string{"--verb"}}); err != nil {
+		t.Fatalf("WithPrefixMatching([--verb]) = %v", err)
+	}
+	equals(t, *verbose, true)
+	equals(t, opt.AllowPrefix, false)
+}
+
+func TestCompPwsh(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	set := CommandSet{}
+	set.Register("serve", "serve files", func(ctx *Context) error {
+		pos, opt := Flags()
+		opt.Int('p', "port", 80, "port to listen on")
+		return ctx.Parse(pos, opt)
+	})
+
+	ctx := &Context{Name: []string{"mytool"}, Args: []string{"generate-completions"}}
+	if err := set.Compile()(ctx); err != nil {
+		t.Fatalf("generate-completions: %v", err)
+	}
+
+	b, err := os.ReadFile("mytool-completion.ps1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := string(b)
+	for _, want := range []string{
+		"$__mytool_completions = @{}",
+		"$__mytool_completions['mytool serve'] = @(",
+		"    ,@('-p', 'ParameterName', 'port to listen on')",
+		"    ,@('--port', 'ParameterName', 'port to listen on')",
+		"    ,@('serve', 'ParameterValue', 'serve files')",
+		"Register-ArgumentCompleter -Native -CommandName 'mytool' -ScriptBlock $__mytool_completer",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("completion script does not contain %q", want)
+		}
+	}
+}
+
+func TestCompFish(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	set := CommandSet{}
+	set.Register("serve", "serve files", func(ctx *Context) error {
+		pos, opt := Flags()
+		pos.String("root", "root directory")
+		opt.Int('p', "port", 80, "port to listen on")
+		opt.Switch('v', "verbose", "verbose output")
+		opt.StringSlice(0, "tag", nil, "tags to apply")
+		return ctx.Parse(pos, opt)
+	})
+	set.Register("status", "show status", func(ctx *Context) error {
+		pos, opt := Flags()
+		return ctx.Parse(pos, opt)
+	})
+
+	ctx := &Context{Name: []string{"mytool"}, Args: []string{"generate-completions"}}
+	if err := WithConfig("mytool.json", set.Compile())(ctx); err != nil {
+		t.Fatalf("generate-completions: %v", err)
+	}
+
+	b, err := os.ReadFile("mytool-completion.fish")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `set -g __fish_mytool_values
+
+function __fish_mytool_words
+    set -l words
+    set -l skip 0
+    for word in (commandline -opc)
+        if test $skip -eq 1
+            set skip 0
+        else if string match -q -- '-*' $word
+            for n in (seq (count $words) -1 1)
+                if contains -- "$words[1..$n] $word" $__fish_mytool_values
+                    set skip 1
+                    break
+                end
+            end
+        else
+            set -a words $word
+        end
+    end
+    printf '%s\n' $words
+end
+
+function __fish_mytool_at_command
+    set -l words (__fish_mytool_words)
+    test "$words" = "$argv"
+end
+
+function __fish_mytool_in_command
+    set -l words (__fish_mytool_words)
+    test (count $words) -ge (count $argv)
+    and test "$words[1..(count $argv)]" = "$argv"
+end
+
+function __fish_mytool_positional
+    set -l n $argv[1]
+    set -l cmd $argv[2..-1]
+    set -l words (__fish_mytool_words)
+    test (count $words) -eq (math (count $cmd) + $n - 1)
+    and test "$words[1..(count $cmd)]" = "$cmd"
+end
+
+set -ga __fish_mytool_values 'mytool serve --config' 'mytool serve -p' 'mytool serve --port' 'mytool serve --tag'
+complete -c mytool -n '__fish_mytool_in_command mytool serve' -s h -l help -d 'show help'
+complete -c mytool -n '__fish_mytool_in_command mytool serve' -l config -r -F -d 'configuration file to read argument values from'
+complete -c mytool -n '__fish_mytool_in_command mytool serve' -s p -l port -r -f -d 'port to listen on'
+complete -c mytool -n '__fish_mytool_in_command mytool serve' -l tag -r -F -d 'tags to apply'
+complete -c mytool -n '__fish_mytool_in_command mytool serve' -s v -l verbose -d 'verbose output'
+complete -c mytool -n '__fish_mytool_in_command mytool serve' -l no-verbose -d 'negate --verbose'
+complete -c mytool -n '__fish_mytool_positional 1 mytool serve' -F -d 'root directory'
+
+set -ga __fish_mytool_values 'mytool status --config'
+complete -c mytool -n '__fish_mytool_in_command mytool status' -s h -l help -d 'show help'
+complete -c mytool -n '__fish_mytool_in_command mytool status' -l config -r -F -d 'configuration file to read argument values from'
+
+set -ga __fish_mytool_values 'mytool --config'
+complete -c mytool -n '__fish_mytool_at_command mytool' -f
+complete -c mytool -n '__fish_mytool_at_command mytool' -s h -l help -d 'show help'
+complete -c mytool -n '__fish_mytool_at_command mytool' -a serve -d 'serve files'
+complete -c mytool -n '__fish_mytool_at_command mytool' -a status -d 'show status'
+
+`
+	equals(t, string(b), want)
+}
+
+func TestCompHints(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	set := CommandSet{}
+	set.Register("build", "build files", func(ctx *Context) error {
+		pos, opt := Flags()
+		pos.String("manifest", "manifest file")
+		pos.Extra("paths", "source paths")
+		opt.Int('j', "jobs", 1, "number of jobs")
+		opt.String('o', "output", ".", "output directory")
+		opt.String('f', "format", "tar", "archive format")
+		opt.SetHint("output", Hint{Type: HintDirs})
+		opt.SetHint("format", Hint{Type: HintWords, Words: []string{"tar", "zip"}})
+		opt.String(0, "label", "", "archive label")
+		opt.SetHint("label", Hint{Type: HintWords, Words: []string{"a b", "(c):d", "it's"}})
+		pos.SetHint("manifest", Hint{Type: HintFiles, Pattern: "*.json"})
+		return ctx.Parse(pos, opt)
+	})
+
+	ctx := &Context{Name: []string{"mytool"}, Args: []string{"generate-completions"}}
+	if err := set.Compile()(ctx); err != nil {
+		t.Fatalf("generate-completions: %v", err)
+	}
+
+	scripts := map[string][]string{
+		"mytool-completion.bash": {
+			"    while [[ \"$i\" -lt \"$COMP_CWORD\" && \"${COMP_WORDS[$i]}\" != 'build' ]]",
+			"            -f|--format|-j|--jobs|--label|-o|--output) (( i++ )) ;;",
+			"        -f|--format) __mytool_compgen -W 'tar zip'; return ;;",
+			"        -j|--jobs) COMPREPLY=(); return ;;",
+			"        -o|--output) __mytool_compgen -d; return ;;",
+			`        --label) __mytool_compgen -W ''\''a b'\'' '\''(c):d'\'' '\''it'\''\'\'''\''s'\'''; return ;;`,
+			"                0) __mytool_compgen -o plusdirs -f -X '!*.json' ;;",
+			"                *) __mytool_complete ;;",
+		},
+		"mytool-completion.zsh": {
+			"        \"--format=[archive format]:format:(tar zip)\" \\",
+			"        \"--jobs=[number of jobs]:jobs: \" \\",
+			`        "--label=[archive label]:label:(a\ b \(c\)\:d it's)" \`,
+			"        \"--output=[output directory]:output:_files -/\" \\",
+			"        \"1:manifest:_files -g '*.json'\" \\",
+			"        \"*::paths:__mytool_complete\"",
+		},
+		"mytool-completion.fish": {
+			"complete -c mytool -n '__fish_mytool_in_command mytool build' -s f -l format -r -f -a 'tar zip' -d 'archive format'",
+			"complete -c mytool -n '__fish_mytool_in_command mytool build' -s j -l jobs -r -f -d 'number of jobs'",
+			`complete -c mytool -n '__fish_mytool_in_command mytool build' -l label -r -f -a '\'a b\' \'(c):d\' \'it\\\'s\'' -d 'archive label'`,
+			`complete -c mytool -n '__fish_mytool_positional 1 mytool build' -f -a '(__fish_complete_path (commandline -ct) | string match -r -- \'/$|(^|/)[^/]*\\\\.json$\')' -d 'manifest file'`,
+		},
+	}
+
+	for filename, lines := range scripts {
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range lines {
+			if !strings.Contains(string(b), want+"\n") {
+				t.Errorf("%s does not contain %q", filename, want)
+			}
+		}
+	}
+}
+
+func TestOutput(t *testing.T) {
+	remote := CommandSet{}
+	remote.Register("add", "add a remote", func(ctx *Context) error {
+		pos, opt := Flags()
+		pos.String("name", "remote name")
+		return ctx.Parse(pos, opt)
+	})
+
+	set := CommandSet{}
+	set.Register("remote", "manage remotes", remote.Compile())
+
+	out := MemoryOutput{}
+	f := WithOutput(out, set.Compile())
+	for _, arg := range []string{"generate-completions", "generate-ronn-templates"} {
+		if err := f(&Context{Name: []string{"mytool"}, Args: []string{arg}}); err != nil {
+			t.Fatalf("%s: %v", arg, err)
+		}
+	}
+
+	names := []string{}
+	for name := range out {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	equals(t, names, []string{
+		"mytool-completion.bash",
+		"mytool-completion.fish",
+		"mytool-completion.ps1",
+		"mytool-completion.zsh",
+		"mytool-remote-add.1.ronn",
+		"mytool-remote.1.ronn",
+		"mytool.1.ronn",
+	})
+
+	// Files are replaced rather than appended to, and left untouched if
+	// the generation fails.
+	dir := t.TempDir()
+	f = WithOutput(DirOutput(dir), set.Compile())
+	for i := 0; i < 2; i++ {
+		if err := f(&Context{Name: []string{"mytool"}, Args: []string{"generate-completions"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "mytool-completion.bash"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	equals(t, string(b), string(out["mytool-completion.bash"]))
+
+	set.Register("broken", "fail to parse", func(ctx *Context) error {
+		return errors.New("broken")
+	})
+	dir = t.TempDir()
+	f = WithOutput(DirOutput(dir), set.Compile())
+	if err := f(&Context{Name: []string{"mytool"}, Args: []string{"generate-completions"}}); err == nil {
+		t.Fatal("expected an error")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equals(t, len(entries), 0)
+}
+
+func TestMan(t *testing.T) {
+	set := CommandSet{}
+	set.Register("serve", "serve files", func(ctx *Context) error {
+		pos, opt := Flags()
+		pos.String("root", "root directory")
+		opt.Int('p', "port", 80, "port to listen on")
+		opt.SetEnv("port", "MYTOOL_PORT")
+		opt.Switch('v', "verbose", "verbose output")
+		return ctx.Parse(pos, opt)
+	})
+
+	out := MemoryOutput{}
+	m := Manual{
+		Date:     "2026-01-01",
+		Source:   "mytool 1.0.0",
+		Author:   "Jane Doe",
+		Examples: map[string]string{"mytool serve": "$ mytool serve ."},
+	}
+	f := WithManual(m, WithOutput(out, WithVersion(Version{Major: 1}, set.Compile())))
+	ctx := &Context{Name: []string{"mytool"}, Desc: "a tool", Args: []string{"generate-man-pages"}}
+	if err := f(ctx); err != nil {
+		t.Fatalf("generate-man-pages: %v", err)
+	}
+
+	equals(t, string(out["mytool.1"]), strings.Join([]string{
+		`.TH "MYTOOL" "1" "2026\-01\-01" "mytool 1.0.0"`,
+		`.SH NAME`,
+		`mytool \- a tool`,
+		`.SH SYNOPSIS`,
+		`\fBmytool\fR [\-V | \-\-version] [\-h | \-\-help] <command> [<args>]`,
+		`.SH DESCRIPTION`,
+		`A tool.`,
+		`.SH COMMANDS`,
+		`.TP`,
+		`\fBmytool\-serve\fR(1)`,
+		`Serve files.`,
+		`.SH AUTHORS`,
+		`Jane Doe`,
+		`.SH SEE ALSO`,
+		`\fBmytool\-serve\fR(1)`,
+		``,
+	}, "\n"))
+
+	page := string(out["mytool-serve.1"])
+	for _, want := range []string{
+		".TP\n\\fI<root>\\fR\nRoot directory.\n",
+		".TP\n\\fB\\-p\\fR \\fI<port>\\fR, \\fB\\-\\-port\\fR=\\fI<port>\\fR\n",
+		".SH ENVIRONMENT\n.TP\n\\fBMYTOOL_PORT\\fR\n",
+		".SH EXAMPLES\n.nf\n$ mytool serve .\n.fi\n",
+		".SH SEE ALSO\n\\fBmytool\\fR(1)\n",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("mytool-serve.1 does not contain %q", want)
+		}
+	}
+
+	// The date is only taken from SOURCE_DATE_EPOCH unless given, so that
+	// the pages are reproducible.
+	m.Date = ""
+	for epoch, want := range map[string]string{
+		"1767225600": `.TH "MYTOOL" "1" "2026\-01\-01" "mytool 1.0.0"`,
+		"":           `.TH "MYTOOL" "1" "" "mytool 1.0.0"`,
+	} {
+		t.Setenv("SOURCE_DATE_EPOCH", epoch)
+		f := WithManual(m, WithOutput(out, set.Compile()))
+		ctx := &Context{Name: []string{"mytool"}, Desc: "a tool", Args: []string{"generate-man-pages"}}
+		if err := f(ctx); err != nil {
+			t.Fatalf("generate-man-pages: %v", err)
+		}
+		equals(t, strings.SplitN(string(out["mytool.1"]), "\n", 2)[0], want)
+	}
+}
+
+func TestDocs(t *testing.T) {
+	remote := CommandSet{}
+	remote.Register("add", "add a remote", func(ctx *Context) error {
+		pos, opt := Flags()
+		pos.String("name", "remote name")
+		opt.Switch('f', "fetch", "fetch after adding")
+		return ctx.Parse(pos, opt)
+	})
+
+	set := CommandSet{}
+	set.Register("remote", "manage remotes", remote.Compile())
+	set.Register("status", "show status", func(ctx *Context) error {
+		pos, opt := Flags()
+		return ctx.Parse(pos, opt)
+	})
+
+	out := MemoryOutput{}
+	ctx := &Context{Name: []string{"mytool"}, Desc: "a tool", Args: []string{"generate-docs"}}
+	if err := WithOutput(out, set.Compile())(ctx); err != nil {
+		t.Fatalf("generate-docs: %v", err)
+	}
+
+	equals(t, string(out["index.md"]), strings.Join([]string{
+		"# mytool reference",
+		"",
+		"- [mytool](mytool.md): A tool.",
+		"  - [mytool remote](mytool-remote.md): Manage remotes.",
+		"    - [mytool remote add](mytool-remote-add.md): Add a remote.",
+		"  - [mytool status](mytool-status.md): Show status.",
+		"",
+	}, "\n"))
+
+	equals(t, string(out["mytool-remote-add.md"]), strings.Join([]string{
+		"# mytool remote add",
+		"",
+		"Add a remote.",
+		"",
+		"```",
+		"usage: mytool remote add [-h | --help] [<args>] <name>",
+		"```",
+		"",
+		"## Positional arguments",
+		"",
+		"| Argument | Description |",
+		"| --- | --- |",
+		"| `<name>` | remote name |",
+		"",
+		"## Optional arguments",
+		"",
+		"| Flag | Description |",
+		"| --- | --- |",
+		"| `-f, --[no-]fetch` | fetch after adding |",
+		"",
+		"See also [mytool remote](mytool-remote.md).",
+		"",
+	}, "\n"))
+
+	page := string(out["mytool.html"])
+	for _, want := range []string{
+		"<li><a href=\"#mytool-remote-add\">mytool remote add</a>: Add a remote.</li>",
+		"<section id=\"mytool-remote\">",
+		"<tr><td><a href=\"#mytool-remote-add\"><code>add</code></a></td><td>add a remote</td></tr>",
+		"<pre><code>usage: mytool remote add [-h | --help] [&lt;args&gt;] &lt;name&gt;</code></pre>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("mytool.html does not contain %q", want)
+		}
+	}
+}
+
+func TestDefine(t *testing.T) {
+	runs, port := 0, 0
+
+	remote := CommandSet{}
+	remote.Define("add", "add a remote", func(pos *Positional, opt *Optional) Function {
+		pos.String("name", "remote name")
+		return func(ctx *Context) error {
+			runs++
+			return nil
+		}
+	})
+
+	set := CommandSet{}
+	set.Group("remote", "manage remotes", remote)
+	set.Define("serve", "serve files", func(pos *Positional, opt *Optional) Function {
+		p := opt.Int('p', "port", 80, "port to listen on")
+		return func(ctx *Context) error {
+			runs++
+			port = *p
+			return nil
+		}
+	})
+
+	names := []string{}
+	err := set.Walk([]string{"mytool"}, func(name []string, cmd Command) error {
+		names = append(names, strings.Join(name, " "))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equals(t, names, []string{"mytool remote", "mytool remote add", "mytool serve"})
+
+	_, opt, ok := set["serve"].Inspect()
+	equals(t, ok, true)
+	equals(t, opt.Args.Has("port"), true)
+
+	f := WithOutput(MemoryOutput{}, set.Compile())
+	for _, arg := range []string{"generate-completions", "generate-man-pages", "generate-docs"} {
+		if err := f(&Context{Name: []string{"mytool"}, Args: []string{arg}}); err != nil {
+			t.Fatalf("%s: %v", arg, err)
+		}
+	}
+	b := strings.Builder{}
+	if err := Complete(&Context{Name: []string{"mytool"}, Args: []string{"serve", "--p"}}, set.Compile(), &b); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, b.String(), "--port\tport to listen on\n")
+	equals(t, runs, 0)
+
+	if err := set.Compile()(&Context{Name: []string{"mytool"}, Args: []string{"serve", "-p", "8080"}}); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, runs, 1)
+	equals(t, port, 8080)
+}
+
+func TestSchema(t *testing.T) {
+	remote := CommandSet{}
+	remote.Define("add", "add a remote", func(pos *Positional, opt *Optional) Function {
+		pos.String("name", "remote name")
+		pos.Extra("urls", "remote urls")
+		return nil
+	})
+
+	set := CommandSet{}
+	set.Group("remote", "manage remotes", remote)
+	set.Register("serve", "serve files", func(ctx *Context) error {
+		pos, opt := Flags()
+		opt.Int('p', "port", 80, "port to listen on")
+		opt.SetEnv("port", "PORT")
+		opt.StringSlice(0, "tag", []string{"a", "b"}, "tags")
+		opt.Require("tag")
+		return ctx.Parse(pos, opt)
+	})
+
+	ctx := &Context{Name: []string{"mytool"}, Desc: "a tool"}
+	schema, err := Describe(ctx, set.Compile())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	equals(t, *schema, Schema{
+		Version: SchemaVersion,
+		Command: SchemaCommand{
+			Name:  "mytool",
+			Desc:  "a tool",
+			Usage: "usage: mytool [-h | --help] <command> [<args>]",
+			Commands: []SchemaCommand{
+				{
+					Name:  "remote",
+					Desc:  "manage remotes",
+					Usage: "usage: mytool remote [-h | --help] <command> [<args>]",
+					Commands: []SchemaCommand{{
+						Name:  "add",
+						Desc:  "add a remote",
+						Usage: "usage: mytool remote add [-h | --help] <name> <urls>...",
+						Positional: []SchemaArgument{
+							{Name: "name", Type: "string", Usage: "remote name", Required: true},
+							{Name: "urls", Type: "[]string", Default: "[]", Usage: "remote urls"},
+						},
+					}},
+				},
+				{
+					Name:  "serve",
+					Desc:  "serve files",
+					Usage: "usage: mytool serve [-h | --help] [<args>] --tag=<tag>",
+					Optional: []SchemaArgument{
+						{Name: "port", Short: "p", Type: "int", Default: "80", Usage: "port to listen on", Env: "PORT"},
+						{Name: "tag", Type: "[]string", Default: "[a b]", Usage: "tags", Required: true},
+					},
+				},
+			},
+		},
+	})
+
+	b, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Schema
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, decoded, *schema)
+
+	out := MemoryOutput{}
+	ctx = &Context{Name: []string{"mytool"}, Desc: "a tool", Args: []string{"generate-schema"}}
+	if err := WithOutput(out, set.Compile())(ctx); err != nil {
+		t.Fatalf("generate-schema: %v", err)
+	}
+	decoded = Schema{}
+	if err := json.Unmarshal(out["mytool.schema.json"], &decoded); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, decoded, *schema)
+}
+
+func TestTools(t *testing.T) {
+	var pattern, files []string
+	grep := func(ctx *Context) error {
+		pos, opt := Flags()
+		p := pos.String("pattern", "search pattern")
+		f := pos.Extra("files", "files to search")
+		if err := ctx.Parse(pos, opt); err != nil {
+			return err
+		}
+		pattern, files = append(pattern, *p), *f
+		return nil
+	}
+
+	docs := 0
+	remote := CommandSet{}
+	remote.Register("add", "add a remote", grep)
+
+	set := CommandSet{}
+	set.Register("grep", "search files", grep)
+	set.Group("remote", "manage remotes", remote)
+	set.Register("generate-docs", "generate the docs", func(ctx *Context) error {
+		pos, opt := Flags()
+		if err := ctx.Parse(pos, opt); err != nil {
+			return err
+		}
+		docs++
+		return nil
+	})
+
+	out := MemoryOutput{}
+	f := WithOutput(out, set.Compile())
+	run := func(args ...string) error {
+		return f(&Context{Name: []string{"mytool"}, Args: args})
+	}
+
+	if err := run("grep", "generate-completions", "generate-ronn-templates"); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, pattern, []string{"generate-completions"})
+	equals(t, files, []string{"generate-ronn-templates"})
+
+	if err := run("generate-docs"); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, docs, 1)
+
+	var e *UnknownCommandError
+	if err := run("remote", "generate-completions"); !errors.As(err, &e) {
+		t.Errorf("expected an UnknownCommandError, got %v", err)
+	}
+	equals(t, len(out), 0)
+
+	tools := Tools{"completions": CompletionTool}
+	f = WithTools(tools, WithOutput(out, set.Compile()))
+	if err := run("completions"); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, len(out), 4)
+	if err := run("generate-completions"); !errors.As(err, &e) {
+		t.Errorf("expected an UnknownCommandError, got %v", err)
+	}
+
+	f = WithTools(nil, grep)
+	if err := run("generate-completions", "file.txt"); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, pattern[len(pattern)-1], "generate-completions")
+
+	// The arguments of a single command are never reserved command names,
+	// which are only taken from the environment.
+	clear(out)
+	f = WithOutput(out, grep)
+	if err := run("generate-man-pages", "file.txt"); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, pattern[len(pattern)-1], "generate-man-pages")
+	equals(t, files, []string{"file.txt"})
+	equals(t, len(out), 0)
+
+	t.Setenv(ToolEnv, "generate-man-pages")
+	if err := run("file.txt"); err != nil && !generated(err) {
+		t.Fatal(err)
+	}
+	equals(t, pattern[len(pattern)-1], "generate-man-pages")
+	equals(t, len(out), 1)
+
+	equals(t, TypeOf("generate-completions"), ValueType)
+	equals(t, TypeOf("-5"), ValueType)
+	equals(t, TypeOf("-v"), ShortType)
+}
+
+func TestHelpFlags(t *testing.T) {
+	var host string
+	var usage string
+	serve := func(ctx *Context) error {
+		pos, opt := Flags()
+		h := opt.String('h', "host", "localhost", "host to listen on")
+		opt.Switch('t', "tls", "serve over TLS")
+		if err := ctx.Parse(pos, opt); err != nil {
+			return err
+		}
+		host, usage = *h, Usage(pos, opt)
+		return nil
+	}
+
+	set := CommandSet{}
+	set.Register("serve", "serve files", serve)
+
+	run := func(f Function, args ...string) error {
+		return f(&Context{Name: []string{"mytool"}, Args: args})
+	}
+
+	if err := run(set.Compile(), "serve", "-th", "example.com"); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, host, "example.com")
+	equals(t, usage, "[--help] [<args>]")
+
+	if err := run(set.Compile(), "serve", "--help"); !errors.Is(err, ErrHelp) {
+		t.Errorf("expected ErrHelp, got %v", err)
+	}
+	if err := run(set.Compile(), "-h"); !errors.Is(err, ErrHelp) {
+		t.Errorf("expected ErrHelp, got %v", err)
+	}
+
+	var e *UnknownCommandError
+	if err := run(set.Compile(), "--threshold"); !errors.As(err, &e) {
+		t.Errorf("expected an UnknownCommandError, got %v", err)
+	}
+
+	f := WithHelpFlags(HelpFlags{'?', "usage"}, set.Compile())
+	err := run(f, "-?")
+	var u *UsageError
+	if !errors.As(err, &u) || u.Err != ErrHelp {
+		t.Fatalf("expected ErrHelp, got %v", err)
+	}
+	equals(t, u.Usage, "usage: mytool [-? | --usage] <command> [<args>]")
+	if err := run(f, "serve", "--usage"); !errors.Is(err, ErrHelp) {
+		t.Errorf("expected ErrHelp, got %v", err)
+	}
+	if err := run(f, "-h"); !errors.As(err, &e) {
+		t.Errorf("expected an UnknownCommandError, got %v", err)
+	}
+
+	f = WithHelpFlags(HelpFlags{}, set.Compile())
+	var flag *UnknownFlagError
+	if err := run(f, "serve", "--help"); !errors.As(err, &flag) {
+		t.Errorf("expected an UnknownFlagError, got %v", err)
+	}
+	if err := run(f, "serve"); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, usage, "[<args>]")
+}
+
+func TestVersion(t *testing.T) {
+	verbose := false
+	serve := func(ctx *Context) error {
+		pos, opt := Flags()
+		v := opt.Switch('V', "verbose", "verbose output")
+		if err := ctx.Parse(pos, opt); err != nil {
+			return err
+		}
+		verbose = *v
+		return nil
+	}
+	echo := func(ctx *Context) error {
+		pos, opt := Flags()
+		pos.Extra("words", "words to print")
+		return ctx.Parse(pos, opt)
+	}
+
+	set := CommandSet{}
+	set.Register("echo", "print words", echo)
+	set.Register("serve", "serve files", WithVersion(Version{Major: 2}, serve))
+
+	version := Version{1, 2, 3, "rc.1", "build.5", "abc123", "", ""}
+	equals(t, version.String(), "1.2.3-rc.1+build.5")
+
+	f := WithVersion(version, set.Compile())
+	run := func(args ...string) error {
+		return f(&Context{Name: []string{"mytool"}, Args: args})
+	}
+
+	var e *VersionError
+	if err := run("--version"); !errors.As(err, &e) {
+		t.Fatalf("expected a VersionError, got %v", err)
+	}
+	equals(t, e.Error(), "1.2.3-rc.1+build.5\ncommit: abc123")
+
+	if err := run("-V", "--json"); !errors.As(err, &e) {
+		t.Fatalf("expected a VersionError, got %v", err)
+	}
+	m := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(e.Error()), &m); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, m["version"], "1.2.3-rc.1+build.5")
+	equals(t, m["commit"], "abc123")
+
+	var unknown *UnknownFlagError
+	if err := run("echo", "--version"); !errors.As(err, &unknown) {
+		t.Errorf("expected an UnknownFlagError, got %v", err)
+	}
+
+	if err := run("serve", "-V"); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, verbose, true)
+	if err := run("serve", "--version"); err == nil || err.Error() != "2.0.0" {
+		t.Errorf("expected version 2.0.0, got %v", err)
+	}
+
+	// The version flags are resolved like any other flag of a command.
+	var q *bool
+	quiet := func(ctx *Context) error {
+		pos, opt := Flags()
+		q = opt.Switch('q', "quiet", "quiet output")
+		return ctx.Parse(pos, opt)
+	}
+	g := WithVersion(version, quiet)
+	for _, args := range [][]string{{"-q", "--version"}, {"-qV"}, {"-q", "--vers", "--json"}} {
+		err := WithPrefixMatching(g)(&Context{Name: []string{"mytool"}, Args: args})
+		if !errors.As(err, &e) {
+			t.Fatalf("%q: expected a VersionError, got %v", args, err)
+		}
+		equals(t, e.Version, version)
+		equals(t, e.JSON, args[len(args)-1] == "--json")
+	}
+	ctx := &Context{Name: []string{"mytool"}, Args: []string{"-q", "--", "--version"}}
+	if err := g(ctx); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, *q, true)
+
+	f = WithVersionFlags(VersionFlags{0, "release"}, f)
+	if err := run("--release"); !errors.Is(err, ErrVersion) {
+		t.Errorf("expected ErrVersion, got %v", err)
+	}
+	var u *UsageError
+	if err := run("--help"); !errors.As(err, &u) {
+		t.Fatalf("expected a UsageError, got %v", err)
+	}
+	equals(t, u.Usage, "usage: mytool [--release] [-h | --help] <command> [<args>]")
+}
+
+func TestRichValues(t *testing.T) {
+	pos, opt := Flags()
+	pattern := pos.Regexp("pattern", "search pattern")
+	timeout := opt.Duration('t', "timeout", time.Second, "request timeout")
+	since := opt.Time(0, "since", time.Time{}, "2006-01-02", "start date")
+	endpoint := opt.URL('u', "url", nil, "endpoint")
+	addr := opt.IP(0, "addr", net.IPv4(127, 0, 0, 1), "listen address")
+	allow := opt.IPNet(0, "allow", nil, "allowed network")
+	limit := opt.Size('l', "limit", 1<<20, "size limit")
+	mask := opt.Uint64(0, "mask", 0, "permission mask")
+	offset := opt.Int64(0, "offset", 0, "offset")
+
+	equals(t, opt.Args["limit"].Value.String(), "1MiB")
+	equals(t, Usage(pos, opt), "[-h | --help] [<args>] <pattern>")
+
+	args := []string{
+		"-t", "1m30s", "--since=2024-02-29", "-u", "https://example.com/api",
+		"--addr", "::1", "--allow", "192.0.2.0/24", "-l", "1.5G",
+		"--mask", "0o755", "--offset", "-0x10", "^a+$",
+	}
+	rest, _, err := parseFlags(pos, opt, args, parseMode{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parsePositional(pos, rest); err != nil {
+		t.Fatal(err)
+	}
+
+	equals(t, *timeout, 90*time.Second)
+	equals(t, since.Format("2006-01-02"), "2024-02-29")
+	equals(t, endpoint.Host, "example.com")
+	equals(t, addr.String(), "::1")
+	equals(t, allow.String(), "192.0.2.0/24")
+	equals(t, *limit, uint64(1500000000))
+	equals(t, *mask, uint64(0755))
+	equals(t, *offset, int64(-16))
+	equals(t, (*pattern).MatchString("aaa"), true)
+	equals(t, opt.Args["limit"].Value.String(), "1500MB")
+
+	// The initial Regexp is shared rather than copied, and replaced on Set.
+	init := regexp.MustCompile("^b+$")
+	exclude := opt.Regexp('x', "exclude", init, "exclude pattern")
+	equals(t, *exclude == init, true)
+	if err := opt.Args["exclude"].Value.Set("^c+$"); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, (*exclude).MatchString("ccc"), true)
+	equals(t, init.String(), "^b+$")
+
+	size := NewSizeValue(0)
+	for s, want := range map[string]uint64{"512": 512, "10MiB": 10 << 20, "2k": 2000, "4 GiB": 4 << 30, "1b": 1, "1.5k": 1500, "18446744073709551615": math.MaxUint64} {
+		if err := size.Set(s); err != nil {
+			t.Errorf("size.Set(%q): %v", s, err)
+		}
+		equals(t, uint64(*size), want)
+	}
+	for _, s := range []string{"", "-1", "10XB", "1.2.3M", "18446744073709551616", "16EiB"} {
+		if err := size.Set(s); err == nil {
+			t.Errorf("size.Set(%q) expected an error", s)
+		}
+	}
+	err = size.Set("10XB")
+	equals(t, err.Error(), "`10XB` cannot be interpreted as uint64")
+
+	err = opt.Args["timeout"].Value.Set("soon")
+	equals(t, err.Error(), "`soon` cannot be interpreted as time.Duration")
+	err = opt.Args["addr"].Value.Set("localhost")
+	equals(t, err.Error(), "`localhost` cannot be interpreted as net.IP")
+
+	help := Help(pos, opt)
+	for _, want := range []string{
+		"-t <duration>, --timeout=<duration>",
+		"--since=<time>",
+		"-u <url>, --url=<url>",
+		"--addr=<ip>",
+		"--allow=<cidr>",
+		"-l <size>, --limit=<size>",
+		"--mask=<uint>",
+	} {
+		if !strings.Contains(help, want) {
+			t.Errorf("help does not contain %q", want)
+		}
+	}
+}
+
+func TestChoice(t *testing.T) {
+	var format, action string
+	var levels []string
+	f := func(ctx *Context) error {
+		pos, opt := Flags()
+		a := pos.Choice("action", []string{"start", "stop"}, "action to take")
+		fm := opt.Choice('f', "format", "json", []string{"json", "yaml", "table"}, "output format")
+		opt.IgnoreCase("format")
+		l := opt.ChoiceSlice(0, "level", nil, []string{"debug", "info"}, "log levels")
+		if err := ctx.Parse(pos, opt); err != nil {
+			return err
+		}
+		format, action, levels = *fm, *a, *l
+		return nil
+	}
+	run := func(args ...string) error {
+		return f(&Context{Name: []string{"mytool"}, Args: args})
+	}
+
+	if err := run("-f", "YAML", "--level", "debug", "--level=info", "start"); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, format, "yaml")
+	equals(t, action, "start")
+	equals(t, levels, []string{"debug", "info"})
+
+	var invalid *InvalidValueError
+	err := run("--format", "xml", "stop")
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected an InvalidValueError, got %v", err)
+	}
+	equals(t, invalid.Err.Error(), "`xml` cannot be interpreted as one of {json,yaml,table}")
+	if err := run("Start"); !errors.As(err, &invalid) {
+		t.Errorf("expected an InvalidValueError, got %v", err)
+	}
+
+	panics(t, func() {
+		_, opt := Flags()
+		opt.Choice('f', "format", "xml", []string{"json", "yaml"}, "output format")
+	})
+	panics(t, func() {
+		_, opt := Flags()
+		opt.ChoiceSlice(0, "level", []string{"trace"}, []string{"debug", "info"}, "log levels")
+	})
+
+	var u *UsageError
+	if err := run("--help"); !errors.As(err, &u) {
+		t.Fatalf("expected a UsageError, got %v", err)
+	}
+	equals(t, u.Usage, "usage: mytool [-h | --help] [<args>] {start,stop}")
+	for _, want := range []string{
+		"{start,stop}",
+		"-f {json,yaml,table}, --format={json,yaml,table}",
+		"--level={debug,info} [--level={debug,info} ...]",
+	} {
+		if !strings.Contains(u.Help, want) {
+			t.Errorf("help does not contain %q", want)
+		}
+	}
+
+	b := strings.Builder{}
+	ctx := &Context{Name: []string{"mytool"}, Args: []string{"-f", "y"}}
+	if err := Complete(ctx, f, &b); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, b.String(), "yaml\n")
+
+	out := MemoryOutput{}
+	t.Setenv(ToolEnv, "generate-completions")
+	ctx = &Context{Name: []string{"mytool"}}
+	if err := WithOutput(out, f)(ctx); err != nil && !generated(err) {
+		t.Fatal(err)
+	}
+	bash := string(out["mytool-completion.bash"])
+	for _, want := range []string{
+		"-f|--format) __mytool_compgen -W 'json yaml table'; return ;;",
+		"0) __mytool_compgen -W 'start stop' ;;",
+	} {
+		if !strings.Contains(bash, want) {
+			t.Errorf("bash completion does not contain %q", want)
+		}
+	}
+	if zsh := string(out["mytool-completion.zsh"]); !strings.Contains(zsh, "(json yaml table)") {
+		t.Errorf("zsh completion does not complete the choices")
+	}
+}
+
+func TestMap(t *testing.T) {
+	pos, opt := Flags()
+	labels := opt.StringMap('l', "label", map[string]string{"env": "dev"}, "labels to set")
+	limits := opt.IntMap(0, "limit", nil, "resource limits")
+	opt.UniqueKeys("limit")
+
+	args := []string{"--label", "env=prod", "-l", "team=infra,tier=web", "--limit=cpu=2,mem=512"}
+	if _, _, err := parseFlags(pos, opt, args, parseMode{}); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, *labels, map[string]string{"env": "prod", "team": "infra", "tier": "web"})
+	equals(t, *limits, map[string]int{"cpu": 2, "mem": 512})
+	equals(t, opt.Args["label"].Value.String(), "env=prod,team=infra,tier=web")
+
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--label", "novalue"}, "`novalue` cannot be interpreted as a key=value pair"},
+		{[]string{"--label", "=value"}, "`=value` cannot be interpreted as a key=value pair"},
+		{[]string{"--limit", "cpu=x"}, "`x` cannot be interpreted as int"},
+		{[]string{"--limit", "cpu=1", "--limit", "cpu=2"}, "key `cpu` is given more than once"},
+	}
+	for _, c := range cases {
+		pos, opt := Flags()
+		opt.StringMap('l', "label", nil, "labels to set")
+		opt.IntMap(0, "limit", nil, "resource limits")
+		opt.UniqueKeys("limit")
+		_, _, err := parseFlags(pos, opt, c.args, parseMode{})
+		var invalid *InvalidValueError
+		if !errors.As(err, &invalid) {
+			t.Errorf("parseFlags(%q): expected an InvalidValueError, got %v", c.args, err)
+			continue
+		}
+		equals(t, invalid.Err.Error(), c.want)
+	}
+
+	help := Help(pos, opt)
+	for _, want := range []string{
+		"-l <key>=<value> [-l <key>=<value> ...]",
+		"--limit=<key>=<value> [--limit=<key>=<value> ...]",
+	} {
+		if !strings.Contains(help, want) {
+			t.Errorf("help does not contain %q", want)
+		}
+	}
+
+	out := MemoryOutput{}
+	f := WithOutput(out, func(ctx *Context) error { return Ronn(ctx, pos, opt) })
+	if err := f(&Context{Name: []string{"mytool"}}); err != nil {
+		t.Fatal(err)
+	}
+	if ronn := string(out["mytool.1.ronn"]); !strings.Contains(ronn, "`-l <key>=<value>`, `--label=<key>=<value>`") {
+		t.Errorf("ronn template does not show the key=value placeholder:\n%s", ronn)
+	}
+}
+
+type region string
+
+func parseRegion(s string) (region, error) {
+	switch s {
+	case "us-east-1", "eu-west-1":
+		return region(s), nil
+	default:
+		return "", fmt.Errorf("unknown region %q", s)
+	}
+}
+
+func TestTyped(t *testing.T) {
+	pos, opt := Flags()
+	target := Pos(pos, "target", "target version", func(s string) (Version, error) {
+		v := Version{}
+		_, err := fmt.Sscanf(s, "%d.%d.%d", &v.Major, &v.Minor, &v.Patch)
+		return v, err
+	})
+	home := Opt(opt, 'r', "region", region("us-east-1"), "home region", parseRegion)
+	replicas := SliceOf(opt, 0, "replica", nil, "replica regions", parseRegion)
+
+	equals(t, opt.Args["region"].Value.String(), "us-east-1")
+	equals(t, schemaType(opt.Args["replica"].Value), "[]flags.region")
+
+	args := []string{"--replica", "eu-west-1", "us-east-1", "-r", "eu-west-1", "1.2.3"}
+	rest, _, err := parseFlags(pos, opt, args, parseMode{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parsePositional(pos, rest); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, *home, region("eu-west-1"))
+	equals(t, *replicas, []region{"eu-west-1", "us-east-1"})
+	equals(t, target.String(), "1.2.3")
+
+	var invalid *InvalidValueError
+	if _, _, err := parseFlags(pos, opt, []string{"-r", "mars"}, parseMode{}); !errors.As(err, &invalid) {
+		t.Fatalf("expected an InvalidValueError, got %v", err)
+	}
+	equals(t, invalid.Err.Error(), "`mars` cannot be interpreted as flags.region: unknown region \"mars\"")
+
+	errParse := errors.New("not a stringer")
+	_, opt2 := Flags()
+	Opt(opt2, 0, "stringer", fmt.Stringer(nil), "a stringer", func(s string) (fmt.Stringer, error) {
+		return nil, errParse
+	})
+	err = opt2.Args["stringer"].Value.Set("x")
+	if !errors.Is(err, errParse) {
+		t.Errorf("expected the parse error to be wrapped, got %v", err)
+	}
+	equals(t, err.Error(), "`x` cannot be interpreted as fmt.Stringer: not a stringer")
+
+	help := Help(pos, opt)
+	for _, want := range []string{
+		"-r <region>, --region=<region>",
+		"--replica=<replica> [--replica=<replica> ...]",
+	} {
+		if !strings.Contains(help, want) {
+			t.Errorf("help does not contain %q", want)
+		}
+	}
+}
+
+func TestCountAndNegation(t *testing.T) {
+	var verbose int
+	var color, cache bool
+	var noCache string
+	f := func(ctx *Context) error {
+		pos, opt := Flags()
+		v := opt.Count('v', "verbose", "verbosity level")
+		c := opt.Switch('c', "color", "colored output")
+		k := opt.Switch(0, "cache", "use the cache")
+		n := opt.String(0, "no-cache", "", "paths not to cache")
+		if err := ctx.Parse(pos, opt); err != nil {
+			return err
+		}
+		verbose, color, cache, noCache = *v, *c, *k, *n
+		return nil
+	}
+	run := func(args ...string) error {
+		return f(&Context{Name: []string{"mytool"}, Args: args})
+	}
+
+	if err := run("-vvc", "--verbose", "-v"); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, verbose, 4)
+	equals(t, color, true)
+
+	if err := run("-vv", "--color", "--no-verbose", "--no-color", "--cache", "--no-cache", "tmp"); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, verbose, 0)
+	equals(t, color, false)
+	equals(t, cache, true)
+	equals(t, noCache, "tmp")
+
+	if err := run("--verbose=2"); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, verbose, 2)
+
+	var invalid *InvalidValueError
+	if err := run("--no-color=false"); !errors.As(err, &invalid) {
+		t.Errorf("expected an InvalidValueError, got %v", err)
+	}
+	if err := run("--verbose=loud"); !errors.As(err, &invalid) {
+		t.Fatalf("expected an InvalidValueError, got %v", err)
+	}
+	equals(t, invalid.Err.Error(), "`loud` cannot be interpreted as int")
+
+	// Negated names are abbreviated like any other with prefix matching.
+	g := WithPrefixMatching(f)
+	if err := g(&Context{Name: []string{"mytool"}, Args: []string{"--color", "--no-col", "--no-cac", "tmp"}}); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, color, false)
+	equals(t, noCache, "tmp")
+	if err := g(&Context{Name: []string{"mytool"}, Args: []string{"-v", "--no-verb"}}); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, verbose, 0)
+
+	var u *UsageError
+	if err := run("--help"); !errors.As(err, &u) {
+		t.Fatalf("expected a UsageError, got %v", err)
+	}
+	for _, want := range []string{
+		"-c, --[no-]color",
+		"-v, --[no-]verbose",
+		"verbosity level (repeatable)",
+		"--cache ",
+	} {
+		if !strings.Contains(u.Help, want) {
+			t.Errorf("help does not contain %q", want)
+		}
+	}
+
+	b := strings.Builder{}
+	ctx := &Context{Name: []string{"mytool"}, Args: []string{"--no-"}}
+	if err := Complete(ctx, f, &b); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, b.String(), "--no-color\tnegate --color\n--no-cache\tpaths not to cache\n--no-verbose\tnegate --verbose\n")
+}
+
+func TestComplete(t *testing.T) {
+	set := CommandSet{}
+	set.Register("serve", "serve files", func(ctx *Context) error {
+		pos, opt := Flags()
+		pos.String("root", "root directory")
+		opt.Int('p', "port", 80, "port to listen on")
+		opt.Switch('v', "verbose", "verbose output")
+		format := opt.String('f', "format", "json", "output format")
+		opt.SetCompleter("format", CompleteFunc(func(prefix string) []string {
+			return []string{"json", "yaml", "table"}
+		}))
+		opt.String('o', "output", ".", "output directory")
+		opt.SetHint("output", Hint{Type: HintDirs})
+		if err := ctx.Parse(pos, opt); err != nil {
+			return err
+		}
+		t.Errorf("serve was run with format %q while completing", *format)
+		return nil
+	})
+	set.Register("status", "show status", func(ctx *Context) error { return nil })
+
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{""}, "serve\tserve files\nstatus\tshow status\n"},
+		{[]string{"se"}, "serve\tserve files\n"},
+		{[]string{"serve", "--f"}, "--format\toutput format\n"},
+		{[]string{"serve", "-f", "y"}, "yaml\n"},
+		{[]string{"serve", "-vf", ""}, "json\nyaml\ntable\n"},
+		{[]string{"serve", "--format=t"}, "--format=table\n"},
+		{[]string{"serve", "--verbose", ""}, ":files\n"},
+		{[]string{"serve", "--port", "80", "root", ""}, ":files\n"},
+		{[]string{"serve", "--port", ""}, ""},
+		{[]string{"serve", "--output", ""}, ":dirs\n"},
+		{[]string{"serve", "--port=80", "--no-verbose", "-o", ""}, ":dirs\n"},
+		{[]string{"serve", "-p80", "-vo", ""}, ":dirs\n"},
+		{[]string{"serve", "--unknown", "--output", ""}, ":dirs\n"},
+		{[]string{"serve", "--port", "eighty", "-f", "t"}, "table\n"},
+		{[]string{"serve", "--form", ""}, ":files\n"},
+	}
+
+	for _, c := range cases {
+		b := strings.Builder{}
+		ctx := &Context{Name: []string{"mytool"}, Args: c.args}
+		if err := Complete(ctx, set.Compile(), &b); err != nil {
+			t.Errorf("Complete(%q): %v", c.args, err)
+		}
+		equals(t, b.String(), c.want)
+	}
+
+	b := strings.Builder{}
+	ctx := &Context{Name: []string{"mytool"}, Args: []string{"serve", "--form", "y"}}
+	if err := Complete(ctx, WithPrefixMatching(set.Compile()), &b); err != nil {
+		t.Fatalf("Complete(serve --form y): %v", err)
+	}
+	equals(t, b.String(), "yaml\n")
+
+	b.Reset()
+	ctx = &Context{Name: []string{"mytool"}, Args: []string{"serve", "--form=y"}}
+	if err := Complete(ctx, WithPrefixMatching(set.Compile()), &b); err != nil {
+		t.Fatalf("Complete(serve --form=y): %v", err)
+	}
+	equals(t, b.String(), "--form=yaml\n")
+
+	// Arguments are never taken as a request to complete.
+	var pattern, file *string
+	grep := func(ctx *Context) error {
+		pos, opt := Flags()
+		pattern = pos.String("pattern", "pattern to search for")
+		file = pos.String("file", "file to search")
+		return ctx.Parse(pos, opt)
+	}
+	ctx = &Context{Name: []string{"grep"}, Args: []string{completeCommand, "file.txt"}}
+	if err := grep(ctx); err != nil {
+		t.Fatalf("grep %s file.txt: %v", completeCommand, err)
+	}
+	equals(t, *pattern, completeCommand)
+	equals(t, *file, "file.txt")
+
+	t.Setenv(CompleteEnv, "1")
+	out := MemoryOutput{}
+	ctx = &Context{Name: []string{"mytool"}, Args: []string{"serve", "--f"}}
+	if err := WithOutput(out, set.Compile())(ctx); err != nil {
+		t.Fatalf("%s=1 serve --f: %v", CompleteEnv, err)
+	}
+	equals(t, string(out[completeCommand]), "--format\toutput format\n")
+
+	out = MemoryOutput{}
+	ctx = &Context{Name: []string{"grep"}, Args: []string{"pattern", ""}}
+	if err := WithOutput(out, grep)(ctx); err != errComplete {
+		t.Fatalf("%s=1 grep pattern: %v", CompleteEnv, err)
+	}
+	equals(t, string(out[completeCommand]), ":files\n")
+}
@@ -2,12 +2,72 @@ package flags
 
 import (
 	"fmt"
-	"sort"
 	"strings"
 
 	"github.com/go-wrap/wrap"
 )
 
+// HelpFlags represents the short and long names of the flags requesting help.
+// A zero Short or an empty Long disables the respective name.
+type HelpFlags struct {
+	Short rune
+	Long  string
+}
+
+// DefaultHelpFlags are the help flags used unless changed by WithHelpFlags.
+var DefaultHelpFlags = HelpFlags{'h', "help"}
+
+type helpKey struct{}
+
+// WithHelpFlags returns a Function which runs f requesting help with the given
+// flags, for every command. Help is disabled by the zero HelpFlags.
+func WithHelpFlags(help HelpFlags, f Function) Function {
+	return func(ctx *Context) error {
+		return f(ctx.with(helpKey{}, help))
+	}
+}
+
+func (ctx Context) helpFlags() HelpFlags {
+	if help, ok := ctx.lookup(helpKey{}).(HelpFlags); ok {
+		return help
+	}
+	return DefaultHelpFlags
+}
+
+// names returns the names of the help flags which are not registered as
+// optional arguments.
+func (help HelpFlags) names(opt *Optional) []string {
+	names := []string{}
+	if help.Short != 0 {
+		if opt == nil || opt.Alias[help.Short] == "" {
+			names = append(names, fmt.Sprintf("-%c", help.Short))
+		}
+	}
+	if help.Long != "" && (opt == nil || !opt.Args.Has(help.Long)) {
+		names = append(names, "--"+help.Long)
+	}
+	return names
+}
+
+// synopsis returns the help flags as shown in the usage, with a leading space.
+func (help HelpFlags) synopsis(opt *Optional) string {
+	names := help.names(opt)
+	if len(names) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" [%s]", strings.Join(names, " | "))
+}
+
+// matches tests if the given argument is exactly one of the help flags.
+func (help HelpFlags) matches(arg string) bool {
+	for _, name := range help.names(nil) {
+		if arg == name {
+			return true
+		}
+	}
+	return false
+}
+
 func formatHelp(name, desc string) string {
 	desc = wrap.Space(desc, 55)
 	desc = strings.Replace(desc, "\n", "\n                        ", -1)
@@ -19,87 +79,204 @@ func formatHelp(name, desc string) string {
 
 // Usage creates a usage string for the given argument definitions.
 func Usage(pos *Positional, opt *Optional) string {
+	if opt == nil {
+		opt = newOptional()
+	}
 	b := strings.Builder{}
-	b.WriteString("[--version] [-h | --help]")
-	if opt != nil && len(opt.Args) > 0 {
+	b.WriteString(opt.builtins())
+	if len(opt.Args) > 0 {
 		b.WriteString(" [<args>]")
 	}
+	for _, c := range opt.Constraints {
+		flags := make([]string, len(c.Names))
+		for i, name := range c.Names {
+			flags[i] = opt.synopsis(name)
+		}
+		switch c.Type {
+		case RequiredConstraint:
+			b.WriteString(" " + strings.Join(flags, " "))
+		case ExclusiveConstraint:
+			b.WriteString(fmt.Sprintf(" [%s]", strings.Join(flags, " | ")))
+		case AtLeastOneConstraint:
+			b.WriteString(fmt.Sprintf(" (%s)", strings.Join(flags, " | ")))
+		}
+	}
 	if pos != nil {
 		for _, name := range pos.Order {
-			switch pos.Args[name].Value.(type) {
-			case *StringSliceValue:
-				b.WriteString(fmt.Sprintf(" <%s>...", name))
-			default:
-				b.WriteString(fmt.Sprintf(" <%s>", name))
-			}
+			b.WriteString(" " + helpPositional(name, pos.Args[name]))
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// builtins returns the version and help flags as shown in the usage.
+func (opt *Optional) builtins() string {
+	return strings.TrimSpace(opt.Version.synopsis(opt) + opt.Help.synopsis(opt))
+}
+
+// builtinFlag represents the names of the help or version flags along with
+// their usage, as completed by the shells.
+type builtinFlag struct {
+	Names []string
+	Usage string
+}
+
+// builtinFlags returns the help and version flags which are not registered
+// as optional arguments, leaving out those without any name.
+func (opt *Optional) builtinFlags() []builtinFlag {
+	flags := []builtinFlag{}
+	if names := opt.Help.names(opt); len(names) > 0 {
+		flags = append(flags, builtinFlag{names, "show help"})
+	}
+	if names := opt.Version.names(opt); len(names) > 0 {
+		flags = append(flags, builtinFlag{names, "print the version number"})
+	}
+	return flags
+}
+
+// helpPositional returns the name of the positional argument as shown in the
+// help, or the list of choices for a ChoiceValue.
+func helpPositional(name string, arg Argument) string {
+	switch arg.Value.(type) {
+	case *StringSliceValue:
+		return fmt.Sprintf("<%s>...", name)
+	case *ChoiceValue:
+		return placeholder(name, arg)
+	default:
+		return fmt.Sprintf("<%s>", name)
+	}
+}
+
+// placeholder returns the value of the argument as shown in the help, which
+// is the list of choices for a ChoiceValue or ChoiceSliceValue, a key=value
+// pair for a map value, and the name of the argument unless the Value is a
+// Placeholder.
+func placeholder(name string, arg Argument) string {
+	switch v := arg.Value.(type) {
+	case *ChoiceValue:
+		return fmt.Sprintf("{%s}", strings.Join(v.Choices, ","))
+	case *ChoiceSliceValue:
+		return fmt.Sprintf("{%s}", strings.Join(v.Choices, ","))
+	case *StringMapValue, *IntMapValue:
+		return "<key>=<value>"
+	case Placeholder:
+		return fmt.Sprintf("<%s>", v.Placeholder())
+	default:
+		return fmt.Sprintf("<%s>", name)
+	}
+}
+
+// negatedName returns the long name of the optional argument as shown in the
+// help, which is prefixed with `[no-]` if the switch may be negated.
+func (opt *Optional) negatedName(long string) string {
+	if opt.negatable(long) {
+		return "[no-]" + long
+	}
+	return long
+}
+
+// helpFlag returns the names of the optional argument as shown in the help.
+func (opt *Optional) helpFlag(short rune, long string) string {
+	arg := opt.Args[long]
+	value := placeholder(long, arg)
+	switch arg.Value.(type) {
+	case SwitchValue:
+		long = opt.negatedName(long)
+		switch short {
+		case 0:
+			return "--" + long
+		default:
+			return fmt.Sprintf("-%c, --%s", short, long)
 		}
+	case SliceValue:
+		switch short {
+		case 0:
+			return fmt.Sprintf("--%[1]s=%[2]s [--%[1]s=%[2]s ...]", long, value)
+		default:
+			return fmt.Sprintf("-%[1]c %[2]s [-%[1]c %[2]s ...]", short, value)
+		}
+	default:
+		switch short {
+		case 0:
+			return fmt.Sprintf("--%s=%s", long, value)
+		default:
+			return fmt.Sprintf("-%[1]c %[3]s, --%[2]s=%[3]s", short, long, value)
+		}
+	}
+}
+
+// usageNotes formats the notes following the usage of an optional argument,
+// telling if it is required, the flags it requires, if it is repeatable and
+// its environment variable, for the help, ronn files and man pages alike.
+type usageNotes struct {
+	Required   string
+	Requires   func(names []string) string
+	Repeatable string
+	Env        func(name string) string
+}
+
+var helpNotes = usageNotes{
+	Required: " (required)",
+	Requires: func(names []string) string {
+		return fmt.Sprintf(" (requires --%s)", strings.Join(names, ", --"))
+	},
+	Repeatable: " (repeatable)",
+	Env: func(name string) string {
+		return fmt.Sprintf(" (env: $%s)", name)
+	},
+}
+
+// annotate appends the notes on the optional argument with the given long
+// name to its usage, with its environment variable given by env.
+func (opt *Optional) annotate(usage, long string, env map[string]string, notes usageNotes) string {
+	if opt.isRequired(long) {
+		usage += notes.Required
 	}
-	return b.String()
+	if names := opt.implied(long); len(names) > 0 {
+		usage += notes.Requires(names)
+	}
+	if _, ok := opt.Args[long].Value.(*CountValue); ok {
+		usage += notes.Repeatable
+	}
+	if name, ok := env[long]; ok {
+		usage += notes.Env(name)
+	}
+	return usage
+}
+
+// helpUsage returns the usage of the optional argument as shown in the help,
+// with its environment variable given by env.
+func (opt *Optional) helpUsage(long string, env map[string]string) string {
+	return opt.annotate(opt.Args[long].Usage, long, env, helpNotes)
 }
 
 // Help creaes a help string for the given argument definitions.
 func Help(pos *Positional, opt *Optional) string {
+	var env map[string]string
+	if opt != nil {
+		env = opt.Env
+	}
+	return help(pos, opt, env)
+}
+
+// help creates the help string with the environment variables given by env.
+func help(pos *Positional, opt *Optional, env map[string]string) string {
 	parts := []string{}
 	if pos != nil {
 		parts = append(parts, "\npositional arguments:")
 		for _, name := range pos.Order {
-			usage := pos.Args[name].Usage
-			switch pos.Args[name].Value.(type) {
-			case *StringSliceValue:
-				name = fmt.Sprintf("<%s>...", name)
-			default:
-				name = fmt.Sprintf("<%s>", name)
-			}
-			parts = append(parts, formatHelp(name, usage))
+			arg := pos.Args[name]
+			parts = append(parts, formatHelp(helpPositional(name, arg), arg.Usage))
 		}
 	}
 
 	if opt != nil {
 		parts = append(parts, "\noptional arguments:")
 
-		names := []optionalName{}
-		for long := range opt.Args {
-			name := optionalName{0, long}
-			for short := range opt.Alias {
-				if opt.Alias[short] == long {
-					name.Short = short
-				}
-			}
-			names = append(names, name)
-		}
-
-		sort.Sort(byShort(names))
-
-		for _, name := range names {
+		for _, name := range opt.sortedNames() {
 			long, short := name.Long, name.Short
-			arg := opt.Args[long]
-			usage := arg.Usage
-			var flag string
-
-			switch arg.Value.(type) {
-			case *BoolValue:
-				switch short {
-				case 0:
-					flag = "--" + long
-				default:
-					flag = fmt.Sprintf("-%c, --%s", short, long)
-				}
-			case SliceValue:
-				switch short {
-				case 0:
-					flag = fmt.Sprintf("--%[1]s=<%[1]s> [--%[1]s=<%[1]s> ...]", long)
-				default:
-					flag = fmt.Sprintf("-%[1]c <%[2]s> [-%[1]c <%[2]s> ...]", short, long)
-				}
-			default:
-				switch short {
-				case 0:
-					flag = fmt.Sprintf("--%[1]s <%[1]s>", long)
-				default:
-					flag = fmt.Sprintf("-%c <%[2]s>, --%[2]s=<%[2]s>", short, long)
-				}
-			}
-			parts = append(parts, formatHelp(flag, usage))
+			flag := opt.helpFlag(short, long)
+			parts = append(parts, formatHelp(flag, opt.helpUsage(long, env)))
 		}
 	}
 	return strings.Join(parts, "\n")
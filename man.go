@@ -0,0 +1,227 @@
+package flags
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Manual represents the details of the manual pages which are not given by
+// the argument definitions. The Examples are keyed by the joined name of the
+// command they illustrate.
+type Manual struct {
+	Section  int
+	Date     string
+	Source   string
+	Author   string
+	Examples map[string]string
+}
+
+type manualKey struct{}
+
+// WithManual returns a Function which runs f generating the manual pages and
+// ronn templates with the given details.
+func WithManual(m Manual, f Function) Function {
+	return func(ctx *Context) error {
+		return f(ctx.with(manualKey{}, m))
+	}
+}
+
+// manual returns the details of the manual pages, defaulting to section 1
+// and to the date given by SOURCE_DATE_EPOCH for reproducible builds. The
+// date is left out of the pages otherwise.
+func (ctx Context) manual() Manual {
+	m, _ := ctx.lookup(manualKey{}).(Manual)
+	if m.Section == 0 {
+		m.Section = 1
+	}
+	if m.Date == "" {
+		if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+			m.Date = time.Unix(epoch, 0).UTC().Format("2006-01-02")
+		}
+	}
+	return m
+}
+
+// roffEscape escapes s to be used as text in a roff document.
+func roffEscape(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\e")
+	s = strings.ReplaceAll(s, "-", "\\-")
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = "\\&" + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// roffQuote escapes s to be used as a macro argument in a roff document.
+func roffQuote(s string) string {
+	return "\"" + strings.ReplaceAll(roffEscape(s), "\"", "\\(dq") + "\""
+}
+
+func roffBold(s string) string {
+	return "\\fB" + roffEscape(s) + "\\fR"
+}
+
+func roffItalic(s string) string {
+	return "\\fI" + roffEscape(s) + "\\fR"
+}
+
+// roffPage returns the title and the NAME section of the manual page.
+func (m Manual) roffPage(name, desc string) []string {
+	return []string{
+		fmt.Sprintf(".TH %s \"%d\" %s %s", roffQuote(strings.ToUpper(name)), m.Section, roffQuote(m.Date), roffQuote(m.Source)),
+		".SH NAME",
+		fmt.Sprintf("%s \\- %s", roffEscape(name), roffEscape(desc)),
+	}
+}
+
+// roffFooter returns the EXAMPLES, AUTHORS and SEE ALSO sections of the
+// manual page of the given command.
+func (m Manual) roffFooter(ctx *Context, seealso []string) []string {
+	parts := []string{}
+	if examples, ok := m.Examples[ctx.JoinedName()]; ok {
+		parts = append(parts, ".SH EXAMPLES", ".nf", roffEscape(strings.TrimSpace(examples)), ".fi")
+	}
+	if m.Author != "" {
+		parts = append(parts, ".SH AUTHORS", roffEscape(m.Author))
+	}
+
+	refs := []string{}
+	if len(ctx.Name) > 1 {
+		refs = append(refs, strings.Join(ctx.Name[:len(ctx.Name)-1], "-"))
+	}
+	refs = append(refs, seealso...)
+	for i, ref := range refs {
+		refs[i] = fmt.Sprintf("%s(%d)", roffBold(ref), m.Section)
+	}
+	if len(refs) > 0 {
+		parts = append(parts, ".SH SEE ALSO", strings.Join(refs, ",\n"))
+	}
+
+	return parts
+}
+
+var manNotes = usageNotes{
+	Required: " This option is required.",
+	Requires: func(names []string) string {
+		flags := make([]string, len(names))
+		for i, name := range names {
+			flags[i] = roffBold("--" + name)
+		}
+		return fmt.Sprintf(" Requires %s.", strings.Join(flags, ", "))
+	},
+	Repeatable: " This option may be repeated.",
+	Env: func(name string) string {
+		return fmt.Sprintf(" Defaults to the value of %s if set.", roffBold("$"+name))
+	},
+}
+
+// Man creates a manual page in roff format.
+func Man(ctx *Context, pos *Positional, opt *Optional) error {
+	env := opt.envNames(ctx.Name)
+	m := ctx.manual()
+	name := strings.Join(ctx.Name, "-")
+	filename := fmt.Sprintf("%s.%d", name, m.Section)
+
+	parts := m.roffPage(name, ctx.Desc)
+	parts = append(parts,
+		".SH SYNOPSIS",
+		roffBold(ctx.JoinedName())+" "+roffEscape(Usage(pos, opt)),
+		".SH DESCRIPTION",
+		roffEscape(sentencify(ctx.Desc)),
+		".SH OPTIONS",
+	)
+
+	for _, name := range pos.Order {
+		arg := pos.Args[name]
+		term := roffItalic(helpPositional(name, arg))
+		parts = append(parts, ".TP", term, roffEscape(sentencify(arg.Usage)))
+	}
+
+	for _, optName := range opt.sortedNames() {
+		short, long := optName.Short, optName.Long
+		arg := opt.Args[long]
+		usage := opt.annotate(roffEscape(sentencify(arg.Usage)), long, env, manNotes)
+
+		var flag string
+		switch arg.Value.(type) {
+		case SwitchValue:
+			flag = roffBold("--" + opt.negatedName(long))
+			if short != 0 {
+				flag = fmt.Sprintf("%s, %s", roffBold(fmt.Sprintf("-%c", short)), flag)
+			}
+		default:
+			value := roffItalic(placeholder(long, arg))
+			flag = fmt.Sprintf("%s=%s", roffBold("--"+long), value)
+			if short != 0 {
+				flag = fmt.Sprintf("%s %s, %s", roffBold(fmt.Sprintf("-%c", short)), value, flag)
+			}
+		}
+
+		parts = append(parts, ".TP", flag, usage)
+	}
+
+	if len(env) > 0 {
+		longs := make([]string, 0, len(env))
+		for long := range env {
+			longs = append(longs, long)
+		}
+		sort.Slice(longs, func(i, j int) bool {
+			return env[longs[i]] < env[longs[j]]
+		})
+
+		parts = append(parts, ".SH ENVIRONMENT")
+		for _, long := range longs {
+			usage := fmt.Sprintf("Used as the value of %s if it is not given.", roffBold("--"+long))
+			parts = append(parts, ".TP", roffBold(env[long]), usage)
+		}
+	}
+
+	parts = append(parts, m.roffFooter(ctx, nil)...)
+	s := strings.Join(parts, "\n") + "\n"
+
+	return ctx.generate(func(ctx *Context) error {
+		ctx.create(filename)
+		ctx.write(filename, s)
+		return nil
+	})
+}
+
+// Man creates a manual page in roff format.
+func (set CommandSet) Man(ctx *Context) error {
+	m := ctx.manual()
+	name := strings.Join(ctx.Name, "-")
+	filename := fmt.Sprintf("%s.%d", name, m.Section)
+
+	parts := m.roffPage(name, ctx.Desc)
+	parts = append(parts,
+		".SH SYNOPSIS",
+		roffBold(ctx.JoinedName())+" "+roffEscape(set.synopsis(ctx)),
+		".SH DESCRIPTION",
+		roffEscape(sentencify(ctx.Desc)),
+		".SH COMMANDS",
+	)
+
+	seealso := []string{}
+	for _, cmdName := range set.Commands() {
+		page := fmt.Sprintf("%s-%s", name, cmdName)
+		term := fmt.Sprintf("%s(%d)", roffBold(page), m.Section)
+		parts = append(parts, ".TP", term, roffEscape(sentencify(set[cmdName].Desc)))
+		seealso = append(seealso, page)
+	}
+
+	parts = append(parts, m.roffFooter(ctx, seealso)...)
+	s := strings.Join(parts, "\n") + "\n"
+
+	return ctx.generate(func(ctx *Context) error {
+		ctx.create(filename)
+		ctx.write(filename, s)
+		return nil
+	})
+}
@@ -2,6 +2,12 @@ package flags
 
 import (
 	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 )
 
 var shortNames = []rune("aAbBcCdDeEfFgGhHiIjJkKlLmMnNoOpPqQrRsStTuUvVwWxXyYzZ")
@@ -51,14 +57,66 @@ func (names byShort) Swap(i, j int) {
 	names[i], names[j] = names[j], names[i]
 }
 
+// sortedNames returns the short and long names of the optional arguments,
+// sorted by their short names or by their long names for those without one.
+func (opt *Optional) sortedNames() []optionalName {
+	names := []optionalName{}
+	for long := range opt.Args {
+		name := optionalName{0, long}
+		for short := range opt.Alias {
+			if opt.Alias[short] == long {
+				name.Short = short
+			}
+		}
+		names = append(names, name)
+	}
+	sort.Sort(byShort(names))
+	return names
+}
+
 // Optional represents the optional command line arguments.
 type Optional struct {
 	Args  Arguments
 	Alias map[rune]string
+
+	// Env maps long names to the environment variables used as fallbacks.
+	Env map[string]string
+
+	// AutoEnv derives an environment variable for each argument without one
+	// from the command name when parsed through a Context.
+	AutoEnv bool
+
+	// EnvSep separates the elements of slice values given through the
+	// environment, and defaults to a comma.
+	EnvSep string
+
+	// AllowPrefix allows long names to be abbreviated to unambiguous prefixes.
+	AllowPrefix bool
+
+	// Help are the names of the flags requesting help, unless registered as
+	// optional arguments.
+	Help HelpFlags
+
+	// Version are the names of the flags requesting the version, unless
+	// registered as optional arguments. They are only set for the commands
+	// declaring a Version.
+	Version VersionFlags
+
+	// Constraints are checked against the arguments given after parsing.
+	Constraints []Constraint
+
+	// Checks are called after parsing and the constraints are satisfied.
+	Checks []func() error
 }
 
 func newOptional() *Optional {
-	return &Optional{Arguments{}, make(map[rune]string)}
+	return &Optional{
+		Args:   Arguments{},
+		Alias:  make(map[rune]string),
+		Env:    make(map[string]string),
+		EnvSep: ",",
+		Help:   DefaultHelpFlags,
+	}
 }
 
 func (opt *Optional) register(short rune, long string, value Value, usage string) {
@@ -71,7 +129,7 @@ func (opt *Optional) register(short rune, long string, value Value, usage string
 	if short != 0 {
 		opt.Alias[short] = long
 	}
-	opt.Args[long] = Argument{value, usage}
+	opt.Args[long] = Argument{Value: value, Usage: usage}
 }
 
 // Switch adds a command line switch to the optional argument list.
@@ -81,6 +139,35 @@ func (opt *Optional) Switch(short rune, long string, usage string) *bool {
 	return (*bool)(value)
 }
 
+// Count adds a command line switch counting its occurrences to the optional
+// argument list.
+func (opt *Optional) Count(short rune, long string, usage string) *int {
+	value := NewCountValue(0)
+	opt.register(short, long, value, usage)
+	return (*int)(value)
+}
+
+// negatable tests if the optional argument with the given long name is a
+// switch which may be negated with `--no-<long>`.
+func (opt *Optional) negatable(long string) bool {
+	_, ok := opt.Args[long].Value.(SwitchValue)
+	return ok && !opt.Args.Has("no-"+long)
+}
+
+// negation returns the long name of the switch negated by the given long
+// name, or the given long name if it does not negate a switch. The name of
+// the switch may be abbreviated if prefix is true.
+func (opt *Optional) negation(long string, prefix bool) (string, bool) {
+	if opt.Args.Has(long) || !strings.HasPrefix(long, "no-") {
+		return long, false
+	}
+	name, err := opt.lookup(strings.TrimPrefix(long, "no-"), prefix)
+	if err != nil || !opt.negatable(name) {
+		return long, false
+	}
+	return name, true
+}
+
 // Int adds an integer flag to the optional argument list.
 func (opt *Optional) Int(short rune, long string, init int, usage string) *int {
 	value := NewIntValue(init)
@@ -122,3 +209,132 @@ func (opt *Optional) StringSlice(short rune, long string, init []string, usage s
 	opt.register(short, long, value, usage)
 	return (*[]string)(value)
 }
+
+// Int64 adds a 64-bit integer flag to the optional argument list.
+func (opt *Optional) Int64(short rune, long string, init int64, usage string) *int64 {
+	value := NewInt64Value(init)
+	opt.register(short, long, value, usage)
+	return (*int64)(value)
+}
+
+// Uint64 adds an unsigned 64-bit integer flag to the optional argument list.
+func (opt *Optional) Uint64(short rune, long string, init uint64, usage string) *uint64 {
+	value := NewUint64Value(init)
+	opt.register(short, long, value, usage)
+	return (*uint64)(value)
+}
+
+// Duration adds a duration flag to the optional argument list.
+func (opt *Optional) Duration(short rune, long string, init time.Duration, usage string) *time.Duration {
+	value := NewDurationValue(init)
+	opt.register(short, long, value, usage)
+	return (*time.Duration)(value)
+}
+
+// Time adds a time flag in the given layout to the optional argument list.
+func (opt *Optional) Time(short rune, long string, init time.Time, layout, usage string) *time.Time {
+	value := NewTimeValue(init, layout)
+	opt.register(short, long, value, usage)
+	return &value.Time
+}
+
+// URL adds a URL flag to the optional argument list.
+func (opt *Optional) URL(short rune, long string, init *url.URL, usage string) *url.URL {
+	value := NewURLValue(init)
+	opt.register(short, long, value, usage)
+	return (*url.URL)(value)
+}
+
+// IP adds an IP address flag to the optional argument list.
+func (opt *Optional) IP(short rune, long string, init net.IP, usage string) *net.IP {
+	value := NewIPValue(init)
+	opt.register(short, long, value, usage)
+	return (*net.IP)(value)
+}
+
+// IPNet adds an IP network flag to the optional argument list.
+func (opt *Optional) IPNet(short rune, long string, init *net.IPNet, usage string) *net.IPNet {
+	value := NewIPNetValue(init)
+	opt.register(short, long, value, usage)
+	return (*net.IPNet)(value)
+}
+
+// Size adds a size in bytes flag to the optional argument list.
+func (opt *Optional) Size(short rune, long string, init uint64, usage string) *uint64 {
+	value := NewSizeValue(init)
+	opt.register(short, long, value, usage)
+	return (*uint64)(value)
+}
+
+// Regexp adds a regular expression flag to the optional argument list. The
+// variable pointed to is set to the Regexp compiled from the value given.
+func (opt *Optional) Regexp(short rune, long string, init *regexp.Regexp, usage string) **regexp.Regexp {
+	value := NewRegexpValue(init)
+	opt.register(short, long, value, usage)
+	return &value.Regexp
+}
+
+// Choice adds a string flag restricted to the given choices to the optional
+// argument list.
+func (opt *Optional) Choice(short rune, long string, init string, choices []string, usage string) *string {
+	value := NewChoiceValue(init, choices)
+	opt.register(short, long, value, usage)
+	return &value.Value
+}
+
+// ChoiceSlice adds a string slice flag restricted to the given choices to the
+// optional argument list.
+func (opt *Optional) ChoiceSlice(short rune, long string, init []string, choices []string, usage string) *[]string {
+	value := NewChoiceSliceValue(init, choices)
+	opt.register(short, long, value, usage)
+	return &value.Values
+}
+
+// IgnoreCase makes the choices of the optional argument with the given long
+// name case-insensitive. The value is set to the choice as registered.
+func (opt *Optional) IgnoreCase(long string) {
+	arg, ok := opt.Args[long]
+	if !ok {
+		panic(fmt.Errorf("optional argument with long name %q does not exist", long))
+	}
+	switch v := arg.Value.(type) {
+	case *ChoiceValue:
+		v.IgnoreCase = true
+	case *ChoiceSliceValue:
+		v.IgnoreCase = true
+	default:
+		panic(fmt.Errorf("optional argument with long name %q is not a choice", long))
+	}
+}
+
+// StringMap adds a key=value pairs flag to the optional argument list.
+func (opt *Optional) StringMap(short rune, long string, init map[string]string, usage string) *map[string]string {
+	value := NewStringMapValue(init)
+	opt.register(short, long, value, usage)
+	return &value.Map
+}
+
+// IntMap adds a key=value pairs flag with integer values to the optional
+// argument list.
+func (opt *Optional) IntMap(short rune, long string, init map[string]int, usage string) *map[string]int {
+	value := NewIntMapValue(init)
+	opt.register(short, long, value, usage)
+	return &value.Map
+}
+
+// UniqueKeys forbids the keys of the map flag with the given long name from
+// being given more than once.
+func (opt *Optional) UniqueKeys(long string) {
+	arg, ok := opt.Args[long]
+	if !ok {
+		panic(fmt.Errorf("optional argument with long name %q does not exist", long))
+	}
+	switch v := arg.Value.(type) {
+	case *StringMapValue:
+		v.Unique = true
+	case *IntMapValue:
+		v.Unique = true
+	default:
+		panic(fmt.Errorf("optional argument with long name %q is not a map", long))
+	}
+}
@@ -0,0 +1,151 @@
+package flags
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Output represents the destination of the files generated for the
+// completion scripts and manual pages.
+type Output interface {
+	WriteFile(name string, data []byte) error
+}
+
+// DirOutput returns an Output writing the files to the given directory, which
+// is created if needed. Existing files are replaced atomically.
+func DirOutput(dir string) Output {
+	return dirOutput(dir)
+}
+
+type dirOutput string
+
+// WriteFile satisfies the Output interface.
+func (dir dirOutput) WriteFile(name string, data []byte) (err error) {
+	if err := os.MkdirAll(string(dir), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(string(dir), "."+name+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(string(dir), name))
+}
+
+// WriterOutput returns an Output writing the content of every file to w, one
+// after another.
+func WriterOutput(w io.Writer) Output {
+	return writerOutput{w}
+}
+
+type writerOutput struct {
+	w io.Writer
+}
+
+// WriteFile satisfies the Output interface.
+func (out writerOutput) WriteFile(name string, data []byte) error {
+	_, err := out.w.Write(data)
+	return err
+}
+
+// MemoryOutput is an Output keeping the content of the files by name.
+type MemoryOutput map[string][]byte
+
+// WriteFile satisfies the Output interface.
+func (out MemoryOutput) WriteFile(name string, data []byte) error {
+	out[name] = append([]byte(nil), data...)
+	return nil
+}
+
+type outputKey struct{}
+
+// WithOutput returns a Function which runs f writing the generated completion
+// scripts, ronn templates and manual pages to out instead of the current
+// directory.
+func WithOutput(out Output, f Function) Function {
+	return func(ctx *Context) error {
+		return f(ctx.with(outputKey{}, out))
+	}
+}
+
+func (ctx Context) output() Output {
+	if out, ok := ctx.lookup(outputKey{}).(Output); ok {
+		return out
+	}
+	return DirOutput(".")
+}
+
+type filesKey struct{}
+
+// files collects the generated files so that none are written unless all of
+// them were generated successfully.
+type files struct {
+	Names   []string
+	Content map[string]*strings.Builder
+}
+
+func (ctx Context) files() *files {
+	fs, _ := ctx.lookup(filesKey{}).(*files)
+	return fs
+}
+
+// generate runs f collecting the files it generates, and writes them to the
+// Output once f is done. Nested calls share the files of the outermost one,
+// and the errors returned once a Tool is run are returned as is once the
+// files are written.
+func (ctx *Context) generate(f Function) error {
+	if ctx.files() != nil {
+		return f(ctx)
+	}
+
+	fs := &files{Content: map[string]*strings.Builder{}}
+	err := f(ctx.with(filesKey{}, fs))
+	if err != nil && !generated(err) {
+		return err
+	}
+
+	out := ctx.output()
+	for _, name := range fs.Names {
+		if err := out.WriteFile(name, []byte(fs.Content[name].String())); err != nil {
+			return ctx.Raise(err)
+		}
+	}
+	return err
+}
+
+// create starts the generated file with the given name over.
+func (ctx Context) create(name string) {
+	fs := ctx.files()
+	if _, ok := fs.Content[name]; !ok {
+		fs.Names = append(fs.Names, name)
+	}
+	fs.Content[name] = &strings.Builder{}
+}
+
+// write appends s to the generated file with the given name.
+func (ctx Context) write(name, s string) {
+	fs := ctx.files()
+	if _, ok := fs.Content[name]; !ok {
+		ctx.create(name)
+	}
+	fs.Content[name].WriteString(s)
+}
@@ -2,7 +2,6 @@ package flags
 
 import (
 	"errors"
-	"fmt"
 	"regexp"
 	"strings"
 )
@@ -32,6 +31,8 @@ func mustMatchString(pattern string, s string) bool {
 	return matched
 }
 
+var errNegatedValue = errors.New("a negated switch cannot be given a value")
+
 // TypeOf returns the type of the given argument.
 func TypeOf(s string) ArgumentType {
 	if s == "--" {
@@ -40,77 +41,130 @@ func TypeOf(s string) ArgumentType {
 	if strings.HasPrefix(s, "--") {
 		return LongType
 	}
-	if mustMatchString("-[^0-9]+", s) {
+	if mustMatchString("^-[^0-9]", s) {
 		return ShortType
 	}
 	return ValueType
 }
 
-var errHelp = errors.New("help")
-var errRonn = errors.New("ronn")
-var errComp = errors.New("comp")
-
 // Parse will parse the argument list according to the positional and optional
 // argument lists provided and return extraneous argument elements and an error
 // value if present.
 func Parse(pos *Positional, opt *Optional, args []string) ([]string, error) {
+	extra, seen, err := parseFlags(pos, opt, args, parseMode{Prefix: opt.AllowPrefix})
+	if err != nil {
+		return nil, err
+	}
+
+	// Environment variables are only used for arguments not given on the
+	// command line, so that the command line always takes precedence.
+	if err := opt.applyEnv(opt.Env, seen); err != nil {
+		return nil, err
+	}
+
+	extra, err = parsePositional(pos, extra)
+	if err != nil {
+		return extra, err
+	}
+
+	return extra, opt.validate(seen)
+}
+
+// source represents where the value of an optional argument was given.
+type source int
+
+const (
+	sourceArgs source = iota + 1
+	sourceEnv
+	sourceConfig
+)
+
+// parseMode represents the settings of parseFlags which are given by the
+// Context rather than the optional argument list.
+type parseMode struct {
+	// Prefix allows long names to be abbreviated to unambiguous prefixes.
+	Prefix bool
+
+	// Complete parses the words preceding the one being completed: unknown
+	// flags and invalid values are ignored, and only a flag awaiting its
+	// value at the end of the words is reported, as a MissingValueError.
+	Complete bool
+}
+
+// parseFlags parses the optional arguments in the argument list and returns
+// the remaining values along with the long names of the arguments given.
+func parseFlags(pos *Positional, opt *Optional, args []string, mode parseMode) ([]string, map[string]source, error) {
 	head := ""
 	extra := []string{}
+	seen := make(map[string]source)
 	terminated := false
 
+	set := func(name string, value Value, s string) error {
+		if err := value.Set(s); err != nil && !mode.Complete {
+			return &InvalidValueError{Name: name, Value: s, Err: err}
+		}
+		return nil
+	}
+
 	for len(args) > 0 && !terminated {
 		head, args = shift(args)
 
-		switch head {
-		case "generate-ronn-templates":
-			return nil, errRonn
-		case "generate-completions":
-			return nil, errComp
-		}
-
 		switch TypeOf(head) {
 		case LongType:
-			long := head[2:]
+			long, value, explicit := head[2:], "", false
+			if i := strings.IndexByte(long, '='); i >= 0 {
+				long, value, explicit = long[:i], long[i+1:], true
+			}
+
+			long, negated := opt.negation(long, mode.Prefix)
+			long, err := opt.lookup(long, mode.Prefix)
+			if err != nil {
+				if mode.Complete {
+					continue
+				}
+				return nil, nil, versionRequest(err, args)
+			}
+			arg := opt.Args[long]
+			seen[long] = sourceArgs
 
-			if long == "help" {
-				return nil, errHelp
+			if negated {
+				if explicit && !mode.Complete {
+					return nil, nil, &InvalidValueError{Name: "no-" + long, Value: value, Err: errNegatedValue}
+				}
+				arg.Value.(SwitchValue).Switch(false)
+				continue
 			}
 
-			switch i := strings.IndexByte(long, '='); i {
-			case -1:
-				arg, ok := opt.Args[long]
-				if !ok {
-					return nil, fmt.Errorf("unknown flag %q", long)
+			if explicit {
+				if err := set(long, arg.Value, value); err != nil {
+					return nil, nil, err
 				}
+				continue
+			}
 
-				switch v := arg.Value.(type) {
-				case *BoolValue:
-					*v = BoolValue(true)
-				case SliceValue:
-					for len(args)+len(extra) > pos.Len() && TypeOf(args[0]) == ValueType {
-						head, args = shift(args)
-						if err := v.Set(head); err != nil {
-							return nil, fmt.Errorf("while setting value for flag %q: %v", long, err)
-						}
-					}
-				default:
+			switch v := arg.Value.(type) {
+			case SwitchValue:
+				v.Switch(true)
+			case SliceValue:
+				if mode.Complete && len(args) == 0 {
+					return nil, nil, &MissingValueError{long}
+				}
+				for len(args) > 0 && len(args)+len(extra) > pos.Len() && TypeOf(args[0]) == ValueType {
 					head, args = shift(args)
-					if TypeOf(head) != ValueType {
-						return nil, fmt.Errorf("while setting value for flag %q: no value given", long)
-					}
-					if err := v.Set(head); err != nil {
-						return nil, fmt.Errorf("while setting value for flag %q: %v", long, err)
+					if err := set(long, v, head); err != nil {
+						return nil, nil, err
 					}
 				}
-
 			default:
-				name, value := long[:1], long[i+1:]
-				arg, ok := opt.Args[name]
-				if !ok {
-					return nil, fmt.Errorf("unknown flag %q", name)
+				if len(args) == 0 || TypeOf(args[0]) != ValueType {
+					if mode.Complete && len(args) > 0 {
+						continue
+					}
+					return nil, nil, &MissingValueError{long}
 				}
-				if err := arg.Value.Set(value); err != nil {
-					return nil, fmt.Errorf("while setting value for flag %q: %v", long, err)
+				head, args = shift(args)
+				if err := set(long, v, head); err != nil {
+					return nil, nil, err
 				}
 			}
 
@@ -121,32 +175,54 @@ func Parse(pos *Positional, opt *Optional, args []string) ([]string, error) {
 			for len(rr) > 0 {
 				r, rr = rr[0], rr[1:]
 
-				if r == 'h' {
-					return nil, errHelp
+				name, err := opt.lookupShort(r)
+				if err != nil {
+					if mode.Complete {
+						continue
+					}
+					return nil, nil, versionRequest(err, args)
 				}
 
-				name, ok := opt.Alias[r]
-				if !ok {
-					return nil, fmt.Errorf("unknown short option `%c`", r)
+				arg := opt.Args[name]
+				seen[name] = sourceArgs
+
+				// A switch may only be given an explicit value with `=`, while
+				// the rest of a bundle following any other option is its value.
+				if v, ok := arg.Value.(SwitchValue); ok && (len(rr) == 0 || rr[0] != '=') {
+					v.Switch(true)
+					continue
+				}
+
+				if len(rr) > 0 {
+					value := strings.TrimPrefix(string(rr), "=")
+					rr = nil
+					if err := set(name, arg.Value, value); err != nil {
+						return nil, nil, err
+					}
+					continue
 				}
 
-				switch v := opt.Args[name].Value.(type) {
-				case *BoolValue:
-					*v = BoolValue(true)
+				switch v := arg.Value.(type) {
 				case SliceValue:
-					for len(args)+len(extra) > pos.Len() && TypeOf(args[0]) == ValueType {
+					if mode.Complete && len(args) == 0 {
+						return nil, nil, &MissingValueError{name}
+					}
+					for len(args) > 0 && len(args)+len(extra) > pos.Len() && TypeOf(args[0]) == ValueType {
 						head, args = shift(args)
-						if err := v.Set(head); err != nil {
-							return nil, fmt.Errorf("while setting value for flag %q: %v", name, err)
+						if err := set(name, v, head); err != nil {
+							return nil, nil, err
 						}
 					}
 				default:
-					head, args = shift(args)
-					if TypeOf(head) != ValueType {
-						return nil, fmt.Errorf("while setting value for flag %q: no value given", name)
+					if len(args) == 0 || TypeOf(args[0]) != ValueType {
+						if mode.Complete && len(args) > 0 {
+							continue
+						}
+						return nil, nil, &MissingValueError{name}
 					}
-					if err := v.Set(head); err != nil {
-						return nil, fmt.Errorf("while setting value for flag %q: %v", name, err)
+					head, args = shift(args)
+					if err := set(name, v, head); err != nil {
+						return nil, nil, err
 					}
 				}
 			}
@@ -159,15 +235,18 @@ func Parse(pos *Positional, opt *Optional, args []string) ([]string, error) {
 		}
 	}
 
+	return extra, seen, nil
+}
+
+// parsePositional assigns the given values to the positional arguments and
+// returns the extraneous values.
+func parsePositional(pos *Positional, extra []string) ([]string, error) {
+	head := ""
 	n := 0
 	for i, name := range pos.Order {
 		if len(extra) == 0 {
-			list := make([]string, len(pos.Order)-i)
-			for j, name := range pos.Order[i:] {
-				list[j] = fmt.Sprintf("%q", name)
-			}
-			missing := strings.Join(list, ", ")
-			return extra, fmt.Errorf("missing positional arguments(s): %s", missing)
+			missing := append([]string{}, pos.Order[i:]...)
+			return extra, &MissingPositionalError{missing}
 		}
 
 		switch pos.Args[name].Value.(type) {
@@ -175,13 +254,13 @@ func Parse(pos *Positional, opt *Optional, args []string) ([]string, error) {
 			for len(extra)+n > pos.Len() {
 				head, extra = shift(extra)
 				if err := pos.Args[name].Value.Set(head); err != nil {
-					return extra, err
+					return extra, &InvalidValueError{Name: name, Value: head, Positional: true, Err: err}
 				}
 			}
 		default:
 			head, extra = shift(extra)
 			if err := pos.Args[name].Value.Set(head); err != nil {
-				return extra, err
+				return extra, &InvalidValueError{Name: name, Value: head, Positional: true, Err: err}
 			}
 			n++
 		}
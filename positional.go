@@ -1,6 +1,12 @@
 package flags
 
-import "fmt"
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"time"
+)
 
 // Positional represents the positional command line arguments.
 type Positional struct {
@@ -17,7 +23,7 @@ func (pos *Positional) register(name string, value Value, usage string) {
 		panic(fmt.Errorf("positional argument with name %q already exists", name))
 	}
 	pos.Order = append(pos.Order, name)
-	pos.Args[name] = Argument{value, usage}
+	pos.Args[name] = Argument{Value: value, Usage: usage}
 }
 
 // Len returns the number of positional arguments.
@@ -59,6 +65,93 @@ func (pos *Positional) String(name, usage string) *string {
 	return (*string)(value)
 }
 
+// Int64 adds a 64-bit integer value to the positional argument list.
+func (pos *Positional) Int64(name, usage string) *int64 {
+	value := NewInt64Value(0)
+	pos.register(name, value, usage)
+	return (*int64)(value)
+}
+
+// Uint64 adds an unsigned 64-bit integer value to the positional argument
+// list.
+func (pos *Positional) Uint64(name, usage string) *uint64 {
+	value := NewUint64Value(0)
+	pos.register(name, value, usage)
+	return (*uint64)(value)
+}
+
+// Duration adds a duration value to the positional argument list.
+func (pos *Positional) Duration(name, usage string) *time.Duration {
+	value := NewDurationValue(0)
+	pos.register(name, value, usage)
+	return (*time.Duration)(value)
+}
+
+// Time adds a time value in the given layout to the positional argument list.
+func (pos *Positional) Time(name, layout, usage string) *time.Time {
+	value := NewTimeValue(time.Time{}, layout)
+	pos.register(name, value, usage)
+	return &value.Time
+}
+
+// URL adds a URL value to the positional argument list.
+func (pos *Positional) URL(name, usage string) *url.URL {
+	value := NewURLValue(nil)
+	pos.register(name, value, usage)
+	return (*url.URL)(value)
+}
+
+// IP adds an IP address value to the positional argument list.
+func (pos *Positional) IP(name, usage string) *net.IP {
+	value := NewIPValue(nil)
+	pos.register(name, value, usage)
+	return (*net.IP)(value)
+}
+
+// IPNet adds an IP network value to the positional argument list.
+func (pos *Positional) IPNet(name, usage string) *net.IPNet {
+	value := NewIPNetValue(nil)
+	pos.register(name, value, usage)
+	return (*net.IPNet)(value)
+}
+
+// Size adds a size in bytes value to the positional argument list.
+func (pos *Positional) Size(name, usage string) *uint64 {
+	value := NewSizeValue(0)
+	pos.register(name, value, usage)
+	return (*uint64)(value)
+}
+
+// Regexp adds a regular expression value to the positional argument list. The
+// variable pointed to is set to the Regexp compiled from the value given.
+func (pos *Positional) Regexp(name, usage string) **regexp.Regexp {
+	value := NewRegexpValue(nil)
+	pos.register(name, value, usage)
+	return &value.Regexp
+}
+
+// Choice adds a string value restricted to the given choices to the
+// positional argument list.
+func (pos *Positional) Choice(name string, choices []string, usage string) *string {
+	value := NewChoiceValue("", choices)
+	pos.register(name, value, usage)
+	return &value.Value
+}
+
+// IgnoreCase makes the choices of the positional argument with the given name
+// case-insensitive. The value is set to the choice as registered.
+func (pos *Positional) IgnoreCase(name string) {
+	arg, ok := pos.Args[name]
+	if !ok {
+		panic(fmt.Errorf("positional argument with name %q does not exist", name))
+	}
+	v, ok := arg.Value.(*ChoiceValue)
+	if !ok {
+		panic(fmt.Errorf("positional argument with name %q is not a choice", name))
+	}
+	v.IgnoreCase = true
+}
+
 // HasExtra returns true if extra arguments are available.
 func (pos *Positional) HasExtra() bool {
 	for _, arg := range pos.Args {
@@ -1,29 +1,39 @@
 package flags
 
 import (
-	"bufio"
 	"fmt"
-	"io"
-	"os"
-	"sort"
 	"strings"
 
 	"github.com/go-wrap/wrap"
 )
 
+// ronnAuthor returns the author given by WithManual, or a placeholder to be
+// filled in the template.
+func (ctx Context) ronnAuthor() string {
+	if author := ctx.manual().Author; author != "" {
+		return author
+	}
+	return "@AUTHOR@"
+}
+
+var ronnNotes = usageNotes{
+	Required: " This option is required.",
+	Requires: func(names []string) string {
+		return fmt.Sprintf(" Requires `--%s`.", strings.Join(names, "`, `--"))
+	},
+	Repeatable: " This option may be repeated.",
+	Env: func(name string) string {
+		return fmt.Sprintf(" Defaults to the value of `$%s` if set.", name)
+	},
+}
+
 // Ronn creates a manpage markdown template for ronn.
 func Ronn(ctx *Context, pos *Positional, opt *Optional) error {
+	env := opt.envNames(ctx.Name)
 	usage := wrap.Space(Usage(pos, opt), 72-len(ctx.JoinedName()))
 	name := strings.Join(ctx.Name, "-")
 	filename := fmt.Sprintf("%s.1.ronn", name)
-
-	f, err := os.Create(filename)
-	if err != nil {
-		return ctx.Raise(err)
-	}
-	defer f.Close()
-
-	w := bufio.NewWriter(f)
+	author := ctx.ronnAuthor()
 
 	parts := []string{
 		fmt.Sprintf("# %s(1) -- %s", name, ctx.Desc),
@@ -40,48 +50,32 @@ func Ronn(ctx *Context, pos *Positional, opt *Optional) error {
 		arg := pos.Args[name]
 		usage := wrap.Space(sentencify(arg.Usage), 76)
 		usage = strings.ReplaceAll(usage, "\n", "    \n")
-		switch pos.Args[name].Value.(type) {
-		case *StringSliceValue:
-			options = append(options, fmt.Sprintf("  * `<%s>...`:\n    %s", name, usage))
-		default:
-			options = append(options, fmt.Sprintf("  * `<%s>`:\n    %s", name, usage))
-		}
+		options = append(options, fmt.Sprintf("  * `%s`:\n    %s", helpPositional(name, arg), usage))
 	}
 
-	optNames := []optionalName{}
-	for long := range opt.Args {
-		optName := optionalName{0, long}
-		for short := range opt.Alias {
-			if opt.Alias[short] == long {
-				optName.Short = short
-			}
-		}
-		optNames = append(optNames, optName)
-	}
-
-	sort.Sort(byShort(optNames))
-
-	for _, optName := range optNames {
+	for _, optName := range opt.sortedNames() {
 		short, long := optName.Short, optName.Long
 		arg := opt.Args[long]
-		usage := wrap.Space(sentencify(arg.Usage), 76)
+		usage := opt.annotate(sentencify(arg.Usage), long, env, ronnNotes)
+		usage = wrap.Space(usage, 76)
 		usage = strings.ReplaceAll(usage, "\n", "    \n")
 		var flag string
 
 		switch arg.Value.(type) {
-		case *BoolValue:
+		case SwitchValue:
 			switch short {
 			case 0:
-				flag = fmt.Sprintf("  * `--%s`:\n", long)
+				flag = fmt.Sprintf("  * `--%s`:\n", opt.negatedName(long))
 			default:
-				flag = fmt.Sprintf("  * `-%c`, `--%s`:\n", short, long)
+				flag = fmt.Sprintf("  * `-%c`, `--%s`:\n", short, opt.negatedName(long))
 			}
 		default:
+			value := placeholder(long, arg)
 			switch short {
 			case 0:
-				flag = fmt.Sprintf("  * `--%[1]s=<%[1]s>`:\n", long)
+				flag = fmt.Sprintf("  * `--%s=%s`:\n", long, value)
 			default:
-				flag = fmt.Sprintf("  * `-%[1]c <%[2]s>`, `--%[2]s=<%[2]s>`:\n", short, long)
+				flag = fmt.Sprintf("  * `-%[1]c %[3]s`, `--%[2]s=%[3]s`:\n", short, long, value)
 			}
 		}
 
@@ -93,22 +87,16 @@ func Ronn(ctx *Context, pos *Positional, opt *Optional) error {
 		"## BUGS",
 		fmt.Sprintf("**%s** currently has no known bugs.", name),
 		"## AUTHORS",
-		fmt.Sprintf("**%s** is written and maintained by @AUTHOR@.", name),
+		fmt.Sprintf("**%s** is written and maintained by %s.", name, author),
 		"## SEE ALSO",
 	}...)
 
 	s := strings.Join(parts, "\n\n")
 	s = wrap.Space(s, 80)
-	if n, err := io.WriteString(w, s); err != nil || n != len(s) {
-		if n != len(s) {
-			return ctx.Raise(fmt.Errorf("wrote %d of %d bytes", n, len(s)))
-		}
-		return ctx.Raise(err)
-	}
-
-	if err := w.Flush(); err != nil {
-		return ctx.Raise(err)
-	}
 
-	return nil
+	return ctx.generate(func(ctx *Context) error {
+		ctx.create(filename)
+		ctx.write(filename, s)
+		return nil
+	})
 }
@@ -0,0 +1,210 @@
+package flags
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SchemaVersion is the version of the Schema, incremented whenever its
+// fields are changed in a way which is not backward compatible.
+const SchemaVersion = 1
+
+// Schema represents the command line interface of a program.
+type Schema struct {
+	Version int           `json:"version"`
+	Command SchemaCommand `json:"command"`
+}
+
+// SchemaCommand represents a command and its subcommands in the Schema.
+type SchemaCommand struct {
+	Name       string           `json:"name"`
+	Desc       string           `json:"desc"`
+	Usage      string           `json:"usage"`
+	Positional []SchemaArgument `json:"positional,omitempty"`
+	Optional   []SchemaArgument `json:"optional,omitempty"`
+	Commands   []SchemaCommand  `json:"commands,omitempty"`
+}
+
+// SchemaArgument represents a positional or optional argument in the Schema.
+// The Short name is only set for optional arguments.
+type SchemaArgument struct {
+	Name     string   `json:"name"`
+	Short    string   `json:"short,omitempty"`
+	Type     string   `json:"type"`
+	Default  string   `json:"default"`
+	Usage    string   `json:"usage"`
+	Env      string   `json:"env,omitempty"`
+	Required bool     `json:"required,omitempty"`
+	Choices  []string `json:"choices,omitempty"`
+}
+
+// schemaType returns the name of the type of the given Value in the Schema.
+func schemaType(value Value) string {
+	switch v := value.(type) {
+	case *BoolValue:
+		return "bool"
+	case *CountValue:
+		return "count"
+	case *IntValue:
+		return "int"
+	case *FloatValue:
+		return "float"
+	case *StringValue:
+		return "string"
+	case *IntSliceValue:
+		return "[]int"
+	case *FloatSliceValue:
+		return "[]float"
+	case *StringSliceValue:
+		return "[]string"
+	case *Int64Value:
+		return "int64"
+	case *Uint64Value:
+		return "uint64"
+	case *DurationValue:
+		return "duration"
+	case *TimeValue:
+		return "time"
+	case *URLValue:
+		return "url"
+	case *IPValue:
+		return "ip"
+	case *IPNetValue:
+		return "cidr"
+	case *SizeValue:
+		return "size"
+	case *RegexpValue:
+		return "regexp"
+	case *ChoiceValue:
+		return "choice"
+	case *ChoiceSliceValue:
+		return "[]choice"
+	case *StringMapValue:
+		return "map[string]string"
+	case *IntMapValue:
+		return "map[string]int"
+	case interface{ typeName() string }:
+		return v.typeName()
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// schemaChoices returns the choices of the given Value, if any.
+func schemaChoices(value Value) []string {
+	switch v := value.(type) {
+	case *ChoiceValue:
+		return v.Choices
+	case *ChoiceSliceValue:
+		return v.Choices
+	default:
+		return nil
+	}
+}
+
+func schemaOf(ctx *Context, pos *Positional, opt *Optional) SchemaCommand {
+	env := opt.envNames(ctx.Name)
+	cmd := SchemaCommand{
+		Name:  ctx.Name[len(ctx.Name)-1],
+		Desc:  ctx.Desc,
+		Usage: fmt.Sprintf("usage: %s %s", ctx.JoinedName(), Usage(pos, opt)),
+	}
+
+	for _, name := range pos.Order {
+		arg := pos.Args[name]
+		_, extra := arg.Value.(*StringSliceValue)
+		cmd.Positional = append(cmd.Positional, SchemaArgument{
+			Name:     name,
+			Type:     schemaType(arg.Value),
+			Default:  arg.Value.String(),
+			Usage:    arg.Usage,
+			Required: !extra,
+			Choices:  schemaChoices(arg.Value),
+		})
+	}
+
+	for _, optName := range opt.sortedNames() {
+		short, long := optName.Short, optName.Long
+		arg := opt.Args[long]
+		schema := SchemaArgument{
+			Name:     long,
+			Type:     schemaType(arg.Value),
+			Default:  arg.Value.String(),
+			Usage:    arg.Usage,
+			Env:      env[long],
+			Required: opt.isRequired(long),
+			Choices:  schemaChoices(arg.Value),
+		}
+		if short != 0 {
+			schema.Short = string(short)
+		}
+		cmd.Optional = append(cmd.Optional, schema)
+	}
+
+	return cmd
+}
+
+func (set CommandSet) schema(ctx *Context) SchemaCommand {
+	return SchemaCommand{
+		Name:  ctx.Name[len(ctx.Name)-1],
+		Desc:  ctx.Desc,
+		Usage: set.usage(ctx),
+	}
+}
+
+type schemaKey struct{}
+
+type schemaResultKey struct{}
+
+// describe adds the command to the Schema, running f to add the subcommands.
+// The Schema is generated once every command is added, and errSchema is
+// returned.
+func (ctx *Context) describe(cmd SchemaCommand, f Function) error {
+	parent, nested := ctx.lookup(schemaKey{}).(*SchemaCommand)
+
+	if f != nil {
+		if err := f(ctx.with(schemaKey{}, &cmd)); err != nil {
+			return err
+		}
+	}
+
+	if nested {
+		parent.Commands = append(parent.Commands, cmd)
+		return errSchema
+	}
+
+	schema := Schema{SchemaVersion, cmd}
+	if result, ok := ctx.lookup(schemaResultKey{}).(*Schema); ok {
+		*result = schema
+		return errSchema
+	}
+
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return ctx.Raise(err)
+	}
+
+	err = ctx.generate(func(ctx *Context) error {
+		filename := fmt.Sprintf("%s.schema.json", ctx.Name[0])
+		ctx.create(filename)
+		ctx.write(filename, string(b)+"\n")
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return errSchema
+}
+
+// Describe returns the Schema of the command line interface of f, which is
+// named after the Context. Commands registered with Define are described
+// without running their Function.
+func Describe(ctx *Context, f Function) (*Schema, error) {
+	schema := &Schema{}
+	child := &Context{ctx.Name, ctx.Desc, nil, ctx.Ctx}
+	child = child.with(toolKey{}, SchemaTool).with(schemaResultKey{}, schema)
+	if err := f(child); err != nil && err != errSchema {
+		return nil, err
+	}
+	return schema, nil
+}
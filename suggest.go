@@ -0,0 +1,155 @@
+package flags
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const maxSuggestions = 3
+
+func levenshtein(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	prev := make([]int, len(t)+1)
+	curr := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		curr[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(t)]
+}
+
+// suggest returns the candidates similar to the given name, ordered by
+// similarity.
+func suggest(name string, candidates []string) []string {
+	type scored struct {
+		Name     string
+		Distance int
+	}
+
+	limit := max(2, len(name)/3)
+	list := []scored{}
+	for _, candidate := range candidates {
+		d := levenshtein(name, candidate)
+		if strings.HasPrefix(candidate, name) {
+			d = 0
+		}
+		if d <= limit {
+			list = append(list, scored{candidate, d})
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Distance != list[j].Distance {
+			return list[i].Distance < list[j].Distance
+		}
+		return list[i].Name < list[j].Name
+	})
+
+	names := []string{}
+	for i := 0; i < len(list) && i < maxSuggestions; i++ {
+		names = append(names, list[i].Name)
+	}
+	return names
+}
+
+// match returns the candidate equal to the given name, or the only candidate
+// prefixed by it if prefix is set. Otherwise, similar candidates are returned.
+func match(name string, candidates []string, prefix bool) (string, []string) {
+	matches := []string{}
+	for _, candidate := range candidates {
+		if candidate == name {
+			return candidate, nil
+		}
+		if strings.HasPrefix(candidate, name) {
+			matches = append(matches, candidate)
+		}
+	}
+	if prefix && len(matches) == 1 {
+		return matches[0], nil
+	}
+	return "", suggest(name, candidates)
+}
+
+func (opt *Optional) longNames() []string {
+	names := make([]string, 0, len(opt.Args))
+	for name := range opt.Args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookup returns the long name of the optional argument given by the long
+// name, or ErrHelp and ErrVersion for the long names of the help and version
+// flags. The long name may be abbreviated if prefix is set.
+func (opt *Optional) lookup(long string, prefix bool) (string, error) {
+	if opt.Args.Has(long) {
+		return long, nil
+	}
+	help, version := opt.Help.Long, opt.Version.Long
+	candidates := opt.longNames()
+	for _, name := range []string{help, version} {
+		if name != "" {
+			candidates = append(candidates, name)
+		}
+	}
+	name, suggestions := match(long, candidates, prefix)
+	switch name {
+	case "":
+		return "", &UnknownFlagError{Long: long, Suggestions: suggestions}
+	case help:
+		return "", ErrHelp
+	case version:
+		return "", ErrVersion
+	}
+	return name, nil
+}
+
+// lookupShort returns the long name of the optional argument given by the
+// short name, or ErrHelp and ErrVersion for the short names of the help and
+// version flags.
+func (opt *Optional) lookupShort(short rune) (string, error) {
+	if name, ok := opt.Alias[short]; ok {
+		return name, nil
+	}
+	if short == opt.Help.Short && short != 0 {
+		return "", ErrHelp
+	}
+	if short == opt.Version.Short && short != 0 {
+		return "", ErrVersion
+	}
+	suggestions := []string{}
+	for _, r := range []rune{unicode.ToLower(short), unicode.ToUpper(short)} {
+		if name, ok := opt.Alias[r]; ok && r != short {
+			suggestions = append(suggestions, name)
+		}
+	}
+	return "", &UnknownFlagError{Short: short, Suggestions: suggestions}
+}
+
+type prefixKey struct{}
+
+// WithPrefixMatching returns a Function which runs f allowing the names of
+// commands and the long names of optional arguments to be abbreviated to any
+// unambiguous prefix, for every command.
+func WithPrefixMatching(f Function) Function {
+	return func(ctx *Context) error {
+		return f(ctx.with(prefixKey{}, true))
+	}
+}
+
+func (ctx Context) prefixMatching() bool {
+	enabled, _ := ctx.lookup(prefixKey{}).(bool)
+	return enabled
+}
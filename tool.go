@@ -0,0 +1,226 @@
+package flags
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Tool represents a generator run in place of the commands when a reserved
+// command name is given as the first argument of the root command.
+type Tool int
+
+// Available tools.
+const (
+	RonnTool Tool = iota + 1
+	ManTool
+	DocsTool
+	SchemaTool
+	CompletionTool
+)
+
+// Tools maps reserved command names to the Tools they run.
+type Tools map[string]Tool
+
+// ToolEnv is the environment variable naming the reserved command to run for
+// a program made of a single command, whose arguments are never taken as
+// reserved command names.
+const ToolEnv = "FLAGS_TOOL"
+
+// DefaultTools are the reserved command names recognized unless WithTools is
+// used.
+var DefaultTools = Tools{
+	"generate-ronn-templates": RonnTool,
+	"generate-man-pages":      ManTool,
+	"generate-docs":           DocsTool,
+	"generate-schema":         SchemaTool,
+	"generate-completions":    CompletionTool,
+}
+
+var errRonn = errors.New("ronn")
+var errMan = errors.New("man")
+var errDocs = errors.New("docs")
+var errSchema = errors.New("schema")
+var errComp = errors.New("comp")
+
+// err returns the error returned by the commands once the Tool is run.
+func (tool Tool) err() error {
+	switch tool {
+	case RonnTool:
+		return errRonn
+	case ManTool:
+		return errMan
+	case DocsTool:
+		return errDocs
+	case SchemaTool:
+		return errSchema
+	default:
+		return errComp
+	}
+}
+
+// generated tests if the error is returned by a command once a Tool is run or
+// the command line is completed, rather than a failure.
+func generated(err error) bool {
+	switch err {
+	case errRonn, errMan, errDocs, errSchema, errComp, errComplete:
+		return true
+	}
+	return false
+}
+
+type toolsKey struct{}
+
+type toolKey struct{}
+
+// WithTools returns a Function which runs f recognizing the given reserved
+// command names instead of DefaultTools. The names are only recognized as the
+// first argument of the root CommandSet, or from ToolEnv for a program made of
+// a single command, and the commands registered with the same names take
+// precedence. A nil Tools disables every Tool.
+func WithTools(tools Tools, f Function) Function {
+	return func(ctx *Context) error {
+		return f(ctx.with(toolsKey{}, tools))
+	}
+}
+
+func (ctx Context) tools() Tools {
+	if tools, ok := ctx.lookup(toolsKey{}).(Tools); ok {
+		return tools
+	}
+	return DefaultTools
+}
+
+func (ctx Context) tool() (Tool, bool) {
+	tool, ok := ctx.lookup(toolKey{}).(Tool)
+	return tool, ok
+}
+
+// toolRoot tests if the first argument of the root CommandSet is a reserved
+// command name and returns the Context to run the Tool with.
+func toolRoot(ctx *Context) (*Context, bool) {
+	if len(ctx.Args) == 0 {
+		return nil, false
+	}
+	return toolNamed(ctx, ctx.Args[0])
+}
+
+// toolEnv tests if the ToolEnv environment variable names a reserved command
+// for a program made of a single command, and returns the Context to run the
+// Tool with.
+func toolEnv(ctx *Context) (*Context, bool) {
+	return toolNamed(ctx, os.Getenv(ToolEnv))
+}
+
+func toolNamed(ctx *Context, name string) (*Context, bool) {
+	if len(ctx.Name) != 1 {
+		return nil, false
+	}
+	if _, ok := ctx.tool(); ok {
+		return nil, false
+	}
+	tool, ok := ctx.tools()[name]
+	if !ok {
+		return nil, false
+	}
+	child := &Context{ctx.Name, ctx.Desc, nil, ctx.Ctx}
+	return child.with(toolKey{}, tool), true
+}
+
+// run runs the Tool for a command with the given argument definitions.
+func (tool Tool) run(ctx *Context, pos *Positional, opt *Optional) error {
+	switch tool {
+	case RonnTool:
+		if err := Ronn(ctx, pos, opt); err != nil {
+			return ctx.Raise(err)
+		}
+		return errRonn
+
+	case ManTool:
+		if err := Man(ctx, pos, opt); err != nil {
+			return ctx.Raise(err)
+		}
+		return errMan
+
+	case DocsTool:
+		return ctx.document(docOf(ctx, pos, opt), nil)
+
+	case SchemaTool:
+		return ctx.describe(schemaOf(ctx, pos, opt), nil)
+
+	default:
+		return ctx.generate(func(ctx *Context) error {
+			compBegin(ctx)
+			if err := Comp(ctx, pos, opt); err != nil {
+				return err
+			}
+			compEnd(ctx)
+			return errComp
+		})
+	}
+}
+
+// runSet runs the Tool for a CommandSet and each of its commands. Nothing is
+// returned for the root command once done.
+func (tool Tool) runSet(ctx *Context, set CommandSet) error {
+	action := map[Tool]string{
+		RonnTool:       "ronn file",
+		ManTool:        "man page",
+		DocsTool:       "docs",
+		SchemaTool:     "schema",
+		CompletionTool: "completion",
+	}[tool]
+
+	commands := func(ctx *Context) error {
+		for _, name := range set.Commands() {
+			cmd := set[name]
+			child := &Context{append(ctx.Name, name), cmd.Desc, nil, ctx.Ctx}
+			if err := cmd.inspect(child); err != tool.err() {
+				return fmt.Errorf("while generating %s for %s: %v", action, name, err)
+			}
+		}
+		return nil
+	}
+
+	var err error
+	switch tool {
+	case RonnTool, ManTool:
+		err = ctx.generate(func(ctx *Context) error {
+			generate := set.Ronn
+			if tool == ManTool {
+				generate = set.Man
+			}
+			if err := generate(ctx); err != nil {
+				return fmt.Errorf("while generating %s for %s: %v", action, ctx.JoinedName(), err)
+			}
+			if err := commands(ctx); err != nil {
+				return err
+			}
+			return tool.err()
+		})
+
+	case DocsTool:
+		err = ctx.document(set.doc(ctx), commands)
+
+	case SchemaTool:
+		err = ctx.describe(set.schema(ctx), commands)
+
+	default:
+		err = ctx.generate(func(ctx *Context) error {
+			compBegin(ctx)
+			if err := commands(ctx); err != nil {
+				return err
+			}
+			if err := set.Comp(ctx); err != nil {
+				return fmt.Errorf("while generating %s for %s: %v", action, ctx.JoinedName(), err)
+			}
+			compEnd(ctx)
+			return errComp
+		})
+	}
+
+	if err == tool.err() && len(ctx.Name) == 1 {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,98 @@
+package flags
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// TypedValue represents an argument value of any type, converted from the
+// command line by the Parse function.
+type TypedValue[T any] struct {
+	Value T
+	Parse func(string) (T, error)
+}
+
+// NewTypedValue creates a new TypedValue.
+func NewTypedValue[T any](init T, parse func(string) (T, error)) *TypedValue[T] {
+	return &TypedValue[T]{init, parse}
+}
+
+// Set will attempt to convert the given string to a value.
+func (p *TypedValue[T]) Set(s string) error {
+	v, err := p.Parse(s)
+	if err != nil {
+		return fmt.Errorf("`%s` cannot be interpreted as %s: %w", s, p.typeName(), err)
+	}
+	p.Value = v
+	return nil
+}
+
+// String satisfies the fmt.Stringer interface.
+func (p TypedValue[T]) String() string {
+	return fmt.Sprint(p.Value)
+}
+
+// typeName returns the name of T, which is also given for interface types.
+func (p TypedValue[T]) typeName() string {
+	return reflect.TypeOf((*T)(nil)).Elem().String()
+}
+
+// TypedSliceValue represents a variable number argument value of any type,
+// converted from the command line by the Parse function.
+type TypedSliceValue[T any] struct {
+	Values []T
+	Parse  func(string) (T, error)
+}
+
+// NewTypedSliceValue creates a new TypedSliceValue.
+func NewTypedSliceValue[T any](init []T, parse func(string) (T, error)) *TypedSliceValue[T] {
+	return &TypedSliceValue[T]{init, parse}
+}
+
+// Len will return the length of the slice value.
+func (p TypedSliceValue[T]) Len() int { return len(p.Values) }
+
+// Set will attempt to convert and append the given string to the slice.
+func (p *TypedSliceValue[T]) Set(s string) error {
+	v, err := p.Parse(s)
+	if err != nil {
+		return fmt.Errorf("`%s` cannot be interpreted as %s: %w", s, p.typeName(), err)
+	}
+	p.Values = append(p.Values, v)
+	return nil
+}
+
+// String satisfies the fmt.Stringer interface.
+func (p TypedSliceValue[T]) String() string {
+	return fmt.Sprintf("%v", p.Values)
+}
+
+// typeName returns the name of []T.
+func (p TypedSliceValue[T]) typeName() string {
+	return reflect.TypeOf((*[]T)(nil)).Elem().String()
+}
+
+// Opt adds a flag of any type converted by parse to the optional argument
+// list.
+func Opt[T any](opt *Optional, short rune, long string, init T, usage string, parse func(string) (T, error)) *T {
+	value := NewTypedValue(init, parse)
+	opt.register(short, long, value, usage)
+	return &value.Value
+}
+
+// SliceOf adds a slice flag of any type converted by parse to the optional
+// argument list.
+func SliceOf[T any](opt *Optional, short rune, long string, init []T, usage string, parse func(string) (T, error)) *[]T {
+	value := NewTypedSliceValue(init, parse)
+	opt.register(short, long, value, usage)
+	return &value.Values
+}
+
+// Pos adds a value of any type converted by parse to the positional argument
+// list.
+func Pos[T any](pos *Positional, name, usage string, parse func(string) (T, error)) *T {
+	var init T
+	value := NewTypedValue(init, parse)
+	pos.register(name, value, usage)
+	return &value.Value
+}
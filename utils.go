@@ -1,9 +1,6 @@
 package flags
 
 import (
-	"bufio"
-	"io"
-	"os"
 	"strings"
 )
 
@@ -24,23 +21,6 @@ func sentencify(s string) string {
 	return s
 }
 
-func osAppend(filename string) (*os.File, error) {
-	flag := os.O_APPEND | os.O_CREATE | os.O_WRONLY
-	return os.OpenFile(filename, flag, 0644)
-}
-
-func fileAppend(filename, s string) error {
-	f, err := osAppend(filename)
-	if err != nil {
-		return err
-	}
-	w := bufio.NewWriter(f)
-	if _, err := io.WriteString(w, s); err != nil {
-		return err
-	}
-	return w.Flush()
-}
-
 func alignLines(s string, c byte) string {
 	lines := strings.Split(s, "\n")
 	indices := make([]int, len(lines))
@@ -64,12 +44,3 @@ func alignLines(s string, c byte) string {
 	}
 	return strings.Join(lines, "\n")
 }
-
-func touch(s string) error {
-	f, err := os.Create(s)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	return nil
-}
@@ -11,3 +11,64 @@ type SliceValue interface {
 	Value
 	Len() int
 }
+
+// SwitchValue represents a command line argument value which is given without
+// a value, and negated by prefixing its long name with `no-`.
+type SwitchValue interface {
+	Value
+	Switch(on bool)
+}
+
+// Completer represents a value which can list the candidates for a partially
+// given command line argument.
+type Completer interface {
+	Complete(prefix string) []string
+}
+
+// CompleteFunc lists the candidates for a partially given argument.
+type CompleteFunc func(prefix string) []string
+
+// Complete satisfies the Completer interface.
+func (f CompleteFunc) Complete(prefix string) []string {
+	return f(prefix)
+}
+
+// HintType represents the kind of completion for a value.
+type HintType int
+
+// Available hint types.
+const (
+	// HintDefault completes values through the Completer if set, and file
+	// names otherwise.
+	HintDefault HintType = iota
+
+	// HintFiles completes file names matching the Pattern if given.
+	HintFiles
+
+	// HintDirs completes directory names.
+	HintDirs
+
+	// HintWords completes the Words.
+	HintWords
+
+	// HintNone completes nothing.
+	HintNone
+)
+
+// Hint represents how the value of an argument is completed by the shell.
+type Hint struct {
+	Type    HintType
+	Pattern string
+	Words   []string
+}
+
+// Hinter represents a value which provides its own completion hint.
+type Hinter interface {
+	Hint() Hint
+}
+
+// Placeholder represents a value which names the kind of value it expects,
+// shown in the help of optional arguments in place of their long name.
+type Placeholder interface {
+	Placeholder() string
+}
@@ -2,7 +2,14 @@ package flags
 
 import (
 	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // BoolValue represents a boolean argument value.
@@ -30,6 +37,60 @@ func (p BoolValue) String() string {
 	return strconv.FormatBool(bool(p))
 }
 
+// Hint satisfies the Hinter interface.
+func (p BoolValue) Hint() Hint {
+	return Hint{Type: HintWords, Words: []string{"false", "true"}}
+}
+
+// Switch satisfies the SwitchValue interface.
+func (p *BoolValue) Switch(on bool) {
+	*p = BoolValue(on)
+}
+
+// CountValue represents a switch argument value counting its occurrences.
+type CountValue int
+
+// NewCountValue creates a new CountValue.
+func NewCountValue(init int) *CountValue {
+	p := new(int)
+	*p = init
+	return (*CountValue)(p)
+}
+
+// Set will attempt to convert the given string to a value, which is either a
+// count or a boolean.
+func (p *CountValue) Set(s string) error {
+	v, err := strconv.Atoi(s)
+	if err == nil && v >= 0 {
+		*p = CountValue(v)
+		return nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("`%s` cannot be interpreted as %T", s, v)
+	}
+	*p = 0
+	if b {
+		*p = 1
+	}
+	return nil
+}
+
+// String satisfies the fmt.Stringer interface.
+func (p CountValue) String() string {
+	return strconv.Itoa(int(p))
+}
+
+// Switch satisfies the SwitchValue interface. The count is incremented when
+// switched on, and reset when switched off.
+func (p *CountValue) Switch(on bool) {
+	if on {
+		*p++
+	} else {
+		*p = 0
+	}
+}
+
 // IntValue represents a integer argument value.
 type IntValue int
 
@@ -55,6 +116,11 @@ func (p IntValue) String() string {
 	return strconv.Itoa(int(p))
 }
 
+// Hint satisfies the Hinter interface.
+func (p IntValue) Hint() Hint {
+	return Hint{Type: HintNone}
+}
+
 // FloatValue represents a float argument value.
 type FloatValue float64
 
@@ -80,6 +146,11 @@ func (p FloatValue) String() string {
 	return strconv.FormatFloat(float64(p), 'g', -1, 64)
 }
 
+// Hint satisfies the Hinter interface.
+func (p FloatValue) Hint() Hint {
+	return Hint{Type: HintNone}
+}
+
 // StringValue represents a string argument value.
 type StringValue string
 
@@ -131,6 +202,11 @@ func (p IntSliceValue) String() string {
 	return fmt.Sprintf("%v", []int(p))
 }
 
+// Hint satisfies the Hinter interface.
+func (p IntSliceValue) Hint() Hint {
+	return Hint{Type: HintNone}
+}
+
 // FloatSliceValue represents a variable number float argument value.
 type FloatSliceValue []float64
 
@@ -161,6 +237,11 @@ func (p FloatSliceValue) String() string {
 	return fmt.Sprintf("%v", []float64(p))
 }
 
+// Hint satisfies the Hinter interface.
+func (p FloatSliceValue) Hint() Hint {
+	return Hint{Type: HintNone}
+}
+
 // StringSliceValue represents a variable number string argument value.
 type StringSliceValue []string
 
@@ -186,3 +267,604 @@ func (p *StringSliceValue) Set(s string) error {
 func (p StringSliceValue) String() string {
 	return fmt.Sprintf("%v", []string(p))
 }
+
+// Int64Value represents a 64-bit integer argument value. Hexadecimal, octal
+// and binary literals are accepted with the 0x, 0o and 0b prefixes.
+type Int64Value int64
+
+// NewInt64Value creates a new Int64Value.
+func NewInt64Value(init int64) *Int64Value {
+	p := new(int64)
+	*p = init
+	return (*Int64Value)(p)
+}
+
+// Set will attempt to convert the given string to a value.
+func (p *Int64Value) Set(s string) error {
+	v, err := strconv.ParseInt(s, 0, 64)
+	if err != nil {
+		return fmt.Errorf("`%s` cannot be interpreted as %T", s, v)
+	}
+	*p = Int64Value(v)
+	return nil
+}
+
+// String satisfies the fmt.Stringer interface.
+func (p Int64Value) String() string {
+	return strconv.FormatInt(int64(p), 10)
+}
+
+// Hint satisfies the Hinter interface.
+func (p Int64Value) Hint() Hint {
+	return Hint{Type: HintNone}
+}
+
+// Placeholder satisfies the Placeholder interface.
+func (p Int64Value) Placeholder() string {
+	return "int"
+}
+
+// Uint64Value represents an unsigned 64-bit integer argument value.
+// Hexadecimal, octal and binary literals are accepted with the 0x, 0o and 0b
+// prefixes.
+type Uint64Value uint64
+
+// NewUint64Value creates a new Uint64Value.
+func NewUint64Value(init uint64) *Uint64Value {
+	p := new(uint64)
+	*p = init
+	return (*Uint64Value)(p)
+}
+
+// Set will attempt to convert the given string to a value.
+func (p *Uint64Value) Set(s string) error {
+	v, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		return fmt.Errorf("`%s` cannot be interpreted as %T", s, v)
+	}
+	*p = Uint64Value(v)
+	return nil
+}
+
+// String satisfies the fmt.Stringer interface.
+func (p Uint64Value) String() string {
+	return strconv.FormatUint(uint64(p), 10)
+}
+
+// Hint satisfies the Hinter interface.
+func (p Uint64Value) Hint() Hint {
+	return Hint{Type: HintNone}
+}
+
+// Placeholder satisfies the Placeholder interface.
+func (p Uint64Value) Placeholder() string {
+	return "uint"
+}
+
+// DurationValue represents a duration argument value, such as `1h30m`.
+type DurationValue time.Duration
+
+// NewDurationValue creates a new DurationValue.
+func NewDurationValue(init time.Duration) *DurationValue {
+	p := new(time.Duration)
+	*p = init
+	return (*DurationValue)(p)
+}
+
+// Set will attempt to convert the given string to a value.
+func (p *DurationValue) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("`%s` cannot be interpreted as %T", s, v)
+	}
+	*p = DurationValue(v)
+	return nil
+}
+
+// String satisfies the fmt.Stringer interface.
+func (p DurationValue) String() string {
+	return time.Duration(p).String()
+}
+
+// Hint satisfies the Hinter interface.
+func (p DurationValue) Hint() Hint {
+	return Hint{Type: HintNone}
+}
+
+// Placeholder satisfies the Placeholder interface.
+func (p DurationValue) Placeholder() string {
+	return "duration"
+}
+
+// TimeValue represents a time argument value in the given layout, which
+// defaults to time.RFC3339.
+type TimeValue struct {
+	Time   time.Time
+	Layout string
+}
+
+// NewTimeValue creates a new TimeValue.
+func NewTimeValue(init time.Time, layout string) *TimeValue {
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	return &TimeValue{init, layout}
+}
+
+// Set will attempt to convert the given string to a value.
+func (p *TimeValue) Set(s string) error {
+	v, err := time.Parse(p.Layout, s)
+	if err != nil {
+		return fmt.Errorf("`%s` cannot be interpreted as %T", s, v)
+	}
+	p.Time = v
+	return nil
+}
+
+// String satisfies the fmt.Stringer interface.
+func (p TimeValue) String() string {
+	if p.Time.IsZero() {
+		return ""
+	}
+	return p.Time.Format(p.Layout)
+}
+
+// Hint satisfies the Hinter interface.
+func (p TimeValue) Hint() Hint {
+	return Hint{Type: HintNone}
+}
+
+// Placeholder satisfies the Placeholder interface.
+func (p TimeValue) Placeholder() string {
+	return "time"
+}
+
+// URLValue represents a URL argument value.
+type URLValue url.URL
+
+// NewURLValue creates a new URLValue, which is empty if init is nil.
+func NewURLValue(init *url.URL) *URLValue {
+	p := new(url.URL)
+	if init != nil {
+		*p = *init
+	}
+	return (*URLValue)(p)
+}
+
+// Set will attempt to convert the given string to a value.
+func (p *URLValue) Set(s string) error {
+	v, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("`%s` cannot be interpreted as %T", s, v)
+	}
+	*p = URLValue(*v)
+	return nil
+}
+
+// String satisfies the fmt.Stringer interface.
+func (p URLValue) String() string {
+	u := url.URL(p)
+	return u.String()
+}
+
+// Hint satisfies the Hinter interface.
+func (p URLValue) Hint() Hint {
+	return Hint{Type: HintNone}
+}
+
+// Placeholder satisfies the Placeholder interface.
+func (p URLValue) Placeholder() string {
+	return "url"
+}
+
+// IPValue represents an IPv4 or IPv6 address argument value.
+type IPValue net.IP
+
+// NewIPValue creates a new IPValue.
+func NewIPValue(init net.IP) *IPValue {
+	p := new(net.IP)
+	*p = init
+	return (*IPValue)(p)
+}
+
+// Set will attempt to convert the given string to a value.
+func (p *IPValue) Set(s string) error {
+	v := net.ParseIP(s)
+	if v == nil {
+		return fmt.Errorf("`%s` cannot be interpreted as %T", s, v)
+	}
+	*p = IPValue(v)
+	return nil
+}
+
+// String satisfies the fmt.Stringer interface.
+func (p IPValue) String() string {
+	if len(p) == 0 {
+		return ""
+	}
+	return net.IP(p).String()
+}
+
+// Hint satisfies the Hinter interface.
+func (p IPValue) Hint() Hint {
+	return Hint{Type: HintNone}
+}
+
+// Placeholder satisfies the Placeholder interface.
+func (p IPValue) Placeholder() string {
+	return "ip"
+}
+
+// IPNetValue represents an IP network argument value in CIDR notation, such
+// as `192.0.2.0/24`.
+type IPNetValue net.IPNet
+
+// NewIPNetValue creates a new IPNetValue, which is empty if init is nil.
+func NewIPNetValue(init *net.IPNet) *IPNetValue {
+	p := new(net.IPNet)
+	if init != nil {
+		*p = *init
+	}
+	return (*IPNetValue)(p)
+}
+
+// Set will attempt to convert the given string to a value.
+func (p *IPNetValue) Set(s string) error {
+	_, v, err := net.ParseCIDR(s)
+	if err != nil {
+		return fmt.Errorf("`%s` cannot be interpreted as %T", s, v)
+	}
+	*p = IPNetValue(*v)
+	return nil
+}
+
+// String satisfies the fmt.Stringer interface.
+func (p IPNetValue) String() string {
+	if len(p.IP) == 0 {
+		return ""
+	}
+	n := net.IPNet(p)
+	return n.String()
+}
+
+// Hint satisfies the Hinter interface.
+func (p IPNetValue) Hint() Hint {
+	return Hint{Type: HintNone}
+}
+
+// Placeholder satisfies the Placeholder interface.
+func (p IPNetValue) Placeholder() string {
+	return "cidr"
+}
+
+// sizeUnits are the multiples of the byte accepted by SizeValue, from the
+// largest to the smallest.
+var sizeUnits = []struct {
+	Suffix string
+	Size   uint64
+}{
+	{"EiB", 1 << 60}, {"PiB", 1 << 50}, {"TiB", 1 << 40},
+	{"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10},
+	{"EB", 1e18}, {"PB", 1e15}, {"TB", 1e12},
+	{"GB", 1e9}, {"MB", 1e6}, {"KB", 1e3},
+	{"B", 1},
+}
+
+// SizeValue represents a size in bytes argument value. Sizes may be given with
+// a decimal unit such as `1.5G` or `1.5GB`, or a binary unit such as `10Mi` or
+// `10MiB`, regardless of case.
+type SizeValue uint64
+
+// NewSizeValue creates a new SizeValue.
+func NewSizeValue(init uint64) *SizeValue {
+	p := new(uint64)
+	*p = init
+	return (*SizeValue)(p)
+}
+
+// Set will attempt to convert the given string to a value.
+func (p *SizeValue) Set(s string) error {
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	number, suffix := s[:i], strings.TrimSpace(s[i:])
+
+	size := uint64(0)
+	if suffix != "" {
+		for _, unit := range sizeUnits {
+			if strings.EqualFold(suffix, unit.Suffix) || strings.EqualFold(suffix+"B", unit.Suffix) {
+				size = unit.Size
+				break
+			}
+		}
+	} else {
+		size = 1
+	}
+
+	// Whole numbers are kept exact, and only fractions are rounded.
+	if size != 0 && !strings.Contains(number, ".") {
+		if n, err := strconv.ParseUint(number, 10, 64); err == nil && n <= math.MaxUint64/size {
+			*p = SizeValue(n * size)
+			return nil
+		}
+	} else if size != 0 {
+		if v, err := strconv.ParseFloat(number, 64); err == nil && v*float64(size) < math.MaxUint64 {
+			*p = SizeValue(math.Round(v * float64(size)))
+			return nil
+		}
+	}
+	return fmt.Errorf("`%s` cannot be interpreted as %T", s, size)
+}
+
+// String satisfies the fmt.Stringer interface. The size is formatted with the
+// largest unit it is a whole multiple of.
+func (p SizeValue) String() string {
+	for _, unit := range sizeUnits {
+		if p != 0 && uint64(p)%unit.Size == 0 {
+			return fmt.Sprintf("%d%s", uint64(p)/unit.Size, unit.Suffix)
+		}
+	}
+	return "0B"
+}
+
+// Hint satisfies the Hinter interface.
+func (p SizeValue) Hint() Hint {
+	return Hint{Type: HintNone}
+}
+
+// Placeholder satisfies the Placeholder interface.
+func (p SizeValue) Placeholder() string {
+	return "size"
+}
+
+// RegexpValue represents a regular expression argument value. The Regexp is
+// replaced by the one compiled from the value given.
+type RegexpValue struct {
+	Regexp *regexp.Regexp
+}
+
+// NewRegexpValue creates a new RegexpValue, which matches any string if init
+// is nil.
+func NewRegexpValue(init *regexp.Regexp) *RegexpValue {
+	if init == nil {
+		init = regexp.MustCompile("")
+	}
+	return &RegexpValue{init}
+}
+
+// Set will attempt to convert the given string to a value.
+func (p *RegexpValue) Set(s string) error {
+	v, err := regexp.Compile(s)
+	if err != nil {
+		return fmt.Errorf("`%s` cannot be interpreted as %T", s, v)
+	}
+	p.Regexp = v
+	return nil
+}
+
+// String satisfies the fmt.Stringer interface.
+func (p *RegexpValue) String() string {
+	return p.Regexp.String()
+}
+
+// Hint satisfies the Hinter interface.
+func (p *RegexpValue) Hint() Hint {
+	return Hint{Type: HintNone}
+}
+
+// Placeholder satisfies the Placeholder interface.
+func (p *RegexpValue) Placeholder() string {
+	return "regexp"
+}
+
+// choose returns the choice matching s, ignoring the case if requested.
+func choose(s string, choices []string, ignoreCase bool) (string, bool) {
+	for _, choice := range choices {
+		if s == choice || (ignoreCase && strings.EqualFold(s, choice)) {
+			return choice, true
+		}
+	}
+	return "", false
+}
+
+// ChoiceValue represents a string argument value which is one of the Choices.
+type ChoiceValue struct {
+	Value      string
+	Choices    []string
+	IgnoreCase bool
+}
+
+// NewChoiceValue creates a new ChoiceValue. It panics if the initial value is
+// neither empty nor one of the choices.
+func NewChoiceValue(init string, choices []string) *ChoiceValue {
+	if _, ok := choose(init, choices, false); !ok && init != "" {
+		panic(fmt.Errorf("initial value %q is not one of {%s}", init, strings.Join(choices, ",")))
+	}
+	return &ChoiceValue{init, choices, false}
+}
+
+// Set will attempt to convert the given string to a value.
+func (p *ChoiceValue) Set(s string) error {
+	v, ok := choose(s, p.Choices, p.IgnoreCase)
+	if !ok {
+		return fmt.Errorf("`%s` cannot be interpreted as one of {%s}", s, strings.Join(p.Choices, ","))
+	}
+	p.Value = v
+	return nil
+}
+
+// String satisfies the fmt.Stringer interface.
+func (p ChoiceValue) String() string {
+	return p.Value
+}
+
+// Hint satisfies the Hinter interface.
+func (p ChoiceValue) Hint() Hint {
+	return Hint{Type: HintWords, Words: p.Choices}
+}
+
+// ChoiceSliceValue represents a variable number string argument value which
+// are each one of the Choices.
+type ChoiceSliceValue struct {
+	Values     []string
+	Choices    []string
+	IgnoreCase bool
+}
+
+// NewChoiceSliceValue creates a new ChoiceSliceValue. It panics if any of the
+// initial values is not one of the choices.
+func NewChoiceSliceValue(init []string, choices []string) *ChoiceSliceValue {
+	for _, v := range init {
+		if _, ok := choose(v, choices, false); !ok {
+			panic(fmt.Errorf("initial value %q is not one of {%s}", v, strings.Join(choices, ",")))
+		}
+	}
+	return &ChoiceSliceValue{init, choices, false}
+}
+
+// Len will return the length of the slice value.
+func (p ChoiceSliceValue) Len() int { return len(p.Values) }
+
+// Set will attempt to convert and append the given string to the slice.
+func (p *ChoiceSliceValue) Set(s string) error {
+	v, ok := choose(s, p.Choices, p.IgnoreCase)
+	if !ok {
+		return fmt.Errorf("`%s` cannot be interpreted as one of {%s}", s, strings.Join(p.Choices, ","))
+	}
+	p.Values = append(p.Values, v)
+	return nil
+}
+
+// String satisfies the fmt.Stringer interface.
+func (p ChoiceSliceValue) String() string {
+	return fmt.Sprintf("%v", p.Values)
+}
+
+// Hint satisfies the Hinter interface.
+func (p ChoiceSliceValue) Hint() Hint {
+	return Hint{Type: HintWords, Words: p.Choices}
+}
+
+// setPairs calls set for each of the comma separated key=value pairs in s,
+// recording the keys given in seen. The keys already seen are rejected if
+// unique is true.
+func setPairs(s string, seen map[string]bool, unique bool, set func(key, value string) error) error {
+	for _, pair := range strings.Split(s, ",") {
+		i := strings.IndexByte(pair, '=')
+		if i <= 0 {
+			return fmt.Errorf("`%s` cannot be interpreted as a key=value pair", pair)
+		}
+		key, value := pair[:i], pair[i+1:]
+		if unique && seen[key] {
+			return fmt.Errorf("key `%s` is given more than once", key)
+		}
+		seen[key] = true
+		if err := set(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatPairs formats the map as comma separated key=value pairs, sorted by
+// key.
+func formatPairs(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + m[key]
+	}
+	return strings.Join(pairs, ",")
+}
+
+// StringMapValue represents a key=value pairs argument value. Pairs may be
+// given repeatedly or as a comma separated list. Each key may only be given
+// once if Unique is true, and replaces the initial value of the key otherwise.
+type StringMapValue struct {
+	Map    map[string]string
+	Unique bool
+	seen   map[string]bool
+}
+
+// NewStringMapValue creates a new StringMapValue with a copy of init.
+func NewStringMapValue(init map[string]string) *StringMapValue {
+	m := make(map[string]string, len(init))
+	for key, value := range init {
+		m[key] = value
+	}
+	return &StringMapValue{Map: m, seen: map[string]bool{}}
+}
+
+// Len will return the number of pairs in the map value.
+func (p StringMapValue) Len() int { return len(p.Map) }
+
+// Set will attempt to convert and add the given pairs to the map.
+func (p *StringMapValue) Set(s string) error {
+	return setPairs(s, p.seen, p.Unique, func(key, value string) error {
+		p.Map[key] = value
+		return nil
+	})
+}
+
+// String satisfies the fmt.Stringer interface.
+func (p StringMapValue) String() string {
+	return formatPairs(p.Map)
+}
+
+// Hint satisfies the Hinter interface.
+func (p StringMapValue) Hint() Hint {
+	return Hint{Type: HintNone}
+}
+
+// IntMapValue represents a key=value pairs argument value with integer
+// values. Pairs are given as for a StringMapValue.
+type IntMapValue struct {
+	Map    map[string]int
+	Unique bool
+	seen   map[string]bool
+}
+
+// NewIntMapValue creates a new IntMapValue with a copy of init.
+func NewIntMapValue(init map[string]int) *IntMapValue {
+	m := make(map[string]int, len(init))
+	for key, value := range init {
+		m[key] = value
+	}
+	return &IntMapValue{Map: m, seen: map[string]bool{}}
+}
+
+// Len will return the number of pairs in the map value.
+func (p IntMapValue) Len() int { return len(p.Map) }
+
+// Set will attempt to convert and add the given pairs to the map.
+func (p *IntMapValue) Set(s string) error {
+	return setPairs(s, p.seen, p.Unique, func(key, value string) error {
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("`%s` cannot be interpreted as %T", value, v)
+		}
+		p.Map[key] = v
+		return nil
+	})
+}
+
+// String satisfies the fmt.Stringer interface.
+func (p IntMapValue) String() string {
+	m := make(map[string]string, len(p.Map))
+	for key, value := range p.Map {
+		m[key] = strconv.Itoa(value)
+	}
+	return formatPairs(m)
+}
+
+// Hint satisfies the Hinter interface.
+func (p IntMapValue) Hint() Hint {
+	return Hint{Type: HintNone}
+}
@@ -1,15 +1,151 @@
 package flags
 
-import "fmt"
+import (
+	"fmt"
+	"runtime/debug"
+)
 
-// Version represents the version of the command.
+// Version represents the version of the command. The Pre and Build fields are
+// the pre-release and build metadata of the semantic version, and the Commit,
+// Date and GoVersion fields describe the build of the command.
 type Version struct {
-	Major int
-	Minor int
-	Patch int
+	Major     int
+	Minor     int
+	Patch     int
+	Pre       string
+	Build     string
+	Commit    string
+	Date      string
+	GoVersion string
 }
 
 // String satisifers the fmt.Stringer interface.
 func (v Version) String() string {
-	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// BuildInfo returns the Version with the Commit, Date and GoVersion fields
+// which are not set taken from the build information of the running binary.
+func (v Version) BuildInfo() Version {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return v
+	}
+	if v.GoVersion == "" {
+		v.GoVersion = info.GoVersion
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if v.Commit == "" {
+				v.Commit = setting.Value
+			}
+		case "vcs.time":
+			if v.Date == "" {
+				v.Date = setting.Value
+			}
+		}
+	}
+	return v
+}
+
+// VersionFlags represents the short and long names of the flags requesting
+// the version. A zero Short or an empty Long disables the respective name.
+type VersionFlags HelpFlags
+
+// DefaultVersionFlags are the version flags used unless changed by
+// WithVersionFlags.
+var DefaultVersionFlags = VersionFlags{'V', "version"}
+
+type versionKey struct{}
+
+type versionFlagsKey struct{}
+
+// versionDecl represents the Version declared for a command.
+type versionDecl struct {
+	Name    string
+	Version Version
+}
+
+// WithVersion returns a Function which runs f declaring the given Version for
+// the command it is run as. The version flags are recognized among the flags
+// of a command, or as the first argument of a CommandSet, and `--json` may
+// follow them to print the Version as JSON.
+func WithVersion(version Version, f Function) Function {
+	return func(ctx *Context) error {
+		return f(ctx.with(versionKey{}, versionDecl{ctx.JoinedName(), version}))
+	}
+}
+
+// WithVersionFlags returns a Function which runs f requesting the version with
+// the given flags.
+func WithVersionFlags(flags VersionFlags, f Function) Function {
+	return func(ctx *Context) error {
+		return f(ctx.with(versionFlagsKey{}, flags))
+	}
+}
+
+// version returns the Version declared for the current command.
+func (ctx Context) version() (Version, bool) {
+	decl, ok := ctx.lookup(versionKey{}).(versionDecl)
+	if !ok || decl.Name != ctx.JoinedName() {
+		return Version{}, false
+	}
+	return decl.Version, true
+}
+
+// versionFlags returns the version flags of the current command, which are
+// disabled unless a Version is declared for it.
+func (ctx Context) versionFlags() VersionFlags {
+	if _, ok := ctx.version(); !ok {
+		return VersionFlags{}
+	}
+	if flags, ok := ctx.lookup(versionFlagsKey{}).(VersionFlags); ok {
+		return flags
+	}
+	return DefaultVersionFlags
+}
+
+// names returns the names of the version flags which are not registered as
+// optional arguments.
+func (flags VersionFlags) names(opt *Optional) []string {
+	return HelpFlags(flags).names(opt)
+}
+
+// synopsis returns the version flags as shown in the usage, with a leading
+// space.
+func (flags VersionFlags) synopsis(opt *Optional) string {
+	return HelpFlags(flags).synopsis(opt)
+}
+
+// versionRequest returns a VersionError for ErrVersion, to be given the
+// Version of the command. It is requested as JSON if `--json` follows the
+// version flag in the remaining arguments.
+func versionRequest(err error, args []string) error {
+	if err == ErrVersion {
+		return &VersionError{JSON: len(args) > 0 && args[0] == "--json"}
+	}
+	return err
+}
+
+// requestVersion returns a VersionError if the arguments of a CommandSet
+// request the version with one of the version flags of opt.
+func (ctx Context) requestVersion(opt *Optional, args []string) error {
+	version, ok := ctx.version()
+	if !ok || len(args) == 0 {
+		return nil
+	}
+	for _, name := range opt.Version.names(opt) {
+		if args[0] == name {
+			return &VersionError{version, len(args) > 1 && args[1] == "--json"}
+		}
+	}
+	return nil
 }